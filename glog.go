@@ -49,6 +49,79 @@
 //	-log_dir=""
 //		Log files will be written to this directory instead of the
 //		default temporary directory.
+//	-log_dir_fallback=true
+//		If -log_dir cannot be created or written, log files are written
+//		to the default temporary directory. If false, logging fails.
+//	-log_format="text"
+//		The format of log lines on standard error and in the log files:
+//		"text" for the header described above followed by the message,
+//		or "logfmt" for ts=... level=INFO threadid=... caller=file.go:10
+//		msg="..." k=v, or "json" for one JSON object per line with the
+//		same keys. Entries such as "stderr=logfmt,file=text" set the
+//		format of one destination.
+//	-log_file_path="base"
+//		The form of the file in the header of log lines and in JSON:
+//		"base" for handler.go, "package" for the import path of the
+//		package and the base name, "module" for that path relative to
+//		the main module, or "func" for the function, such as
+//		sub.(*Server).Handle.
+//	-log_time_format="default"
+//		The format of the time in the header of log lines: "default"
+//		for mmdd hh:mm:ss.uuuuuu, "year" for yyyymmdd hh:mm:ss.uuuuuu
+//		or "rfc3339" for yyyy-mm-ddThh:mm:ss.uuuuuu+hh:mm.
+//	-log_time_utc=false, -log_time_nanos=false
+//		The time in the header is in UTC instead of local time, and
+//		has nanoseconds instead of microseconds.
+//	-log_goroutine_id=false
+//		The threadid column of the header, and the threadid field in
+//		JSON, hold the ID of the logging goroutine instead of the
+//		process ID.
+//	-log_error_policy="exit"
+//		What to do when a log file cannot be created or written: "exit"
+//		the program, write records to "stderr", "drop" them, or "retry"
+//		creating a new file after 10, 20 and 40ms, writing to standard
+//		error in between. Except with "exit", a new file is created
+//		again after 10 seconds.
+//	-log_durability=""
+//		Comma-separated list of SEVERITY=MODE settings for records of
+//		that severity and above. With "buffered", the default, log
+//		files are written when their buffer is full and flushed every
+//		-log_flush_interval (30s); "flush" writes the files after each
+//		record and "sync" also syncs them to disk, as in "ERROR=sync".
+//	-log_buffer_size=262144
+//		The size in bytes of the buffer of each log file.
+//	-log_file_header=true
+//		Each log file starts with a header of the creation time, host,
+//		binary, line format and the lines added by AddFileHeader. If
+//		false, log files only contain log lines.
+//	-log_file_mode=0666, -log_dir_mode=0755
+//		The permissions, before the umask, of new log files and of the
+//		-log_dir directory, which is created if it does not exist.
+//	-log_debug_files=false
+//		DEBUG and TRACE logs are written to their own files instead
+//		of the INFO file.
+//	-log_rotate_interval=""
+//		Log files are also rotated "hourly", "daily" or at multiples of
+//		a duration such as "6h", counted from local midnight.
+//	-log_max_files=0, -log_max_age=0, -log_max_total_size=0
+//		If positive, the oldest log files of this program are deleted
+//		after each rotation to keep at most this number of files, files
+//		younger than this duration, or this number of bytes per severity.
+//		Files of other running processes of this program are kept.
+//	-log_compress=false
+//		Log files are compressed with gzip after rotation.
+//	-log_files=""
+//		If "single", all severities are written to the INFO file only;
+//		"single+error" also writes ERROR and FATAL to the ERROR file.
+//		Entries such as "WARNING=INFO+WARNING" set the files of a
+//		severity. By default each severity is written to its own file
+//		and to those of all lower severities.
+//	-log_file_template=""
+//		If non-empty, the names of log files are made from this template
+//		instead of program.host.user.log.TAG.YYYYMMDD-HHMMSS.pid. Its
+//		placeholders are {program}, {host}, {user}, {pid}, {tag} (or
+//		{severity}), {date}, {time}, {time:layout} with a time.Format
+//		layout and {seq}, the lowest number for which no file exists.
 //
 //	Other flags provide aids to debugging.
 //
@@ -90,21 +163,26 @@ import (
 
 // severity identifies the sort of log: info, warning etc. It also implements
 // the flag.Value interface. The -stderrthreshold flag is of type severity and
-// should be modified only through the flag.Value interface. The values match
-// the corresponding constants in C++.
+// should be modified only through the flag.Value interface. The values shown
+// and accepted by the flag match the corresponding constants in C++, which
+// has no TRACE and DEBUG; these are -2 and -1.
 type severity int32 // sync/atomic int32
 
 const (
-	infoLog severity = iota
+	traceLog severity = iota
+	debugLog
+	infoLog
 	warningLog
 	errorLog
 	fatalLog
-	numSeverity = 4
+	numSeverity = 6
 )
 
-const severityChar = "IWEF"
+const severityChar = "TDIWEF"
 
 var severityName = []string{
+	traceLog:   "TRACE",
+	debugLog:   "DEBUG",
 	infoLog:    "INFO",
 	warningLog: "WARNING",
 	errorLog:   "ERROR",
@@ -123,12 +201,12 @@ func (s *severity) set(val severity) {
 
 // String is part of the flag.Value interface.
 func (s *severity) String() string {
-	return strconv.FormatInt(int64(*s), 10)
+	return strconv.FormatInt(int64(*s-infoLog), 10)
 }
 
-// Get is part of the flag.Value interface.
+// Get is part of the flag.Value interface. Like String, it numbers INFO 0.
 func (s *severity) Get() interface{} {
-	return *s
+	return *s - infoLog
 }
 
 // Set is part of the flag.Value interface.
@@ -142,7 +220,7 @@ func (s *severity) Set(value string) error {
 		if err != nil {
 			return err
 		}
-		threshold = severity(v)
+		threshold = severity(v) + infoLog
 	}
 	logging.stderrThreshold.set(threshold)
 	return nil
@@ -178,9 +256,14 @@ func (s *OutputStats) Bytes() int64 {
 // per severity level. Values must be read with atomic.LoadInt64.
 var Stats struct {
 	Info, Warning, Error OutputStats
+	Debug, Trace         OutputStats
+	// Dropped counts the records not written to their log files by DropOnError.
+	Dropped OutputStats
 }
 
 var severityStats = [numSeverity]*OutputStats{
+	traceLog:   &Stats.Trace,
+	debugLog:   &Stats.Debug,
 	infoLog:    &Stats.Info,
 	warningLog: &Stats.Warning,
 	errorLog:   &Stats.Error,
@@ -279,6 +362,18 @@ var errVmoduleSyntax = errors.New("syntax error: expect comma-separated list of
 
 // Syntax: -vmodule=recordio=2,file=1,gfs*=3
 func (m *moduleSpec) Set(value string) error {
+	filter, err := parseModuleSpec(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.setVState(logging.verbosity, filter, true)
+	return nil
+}
+
+// parseModuleSpec returns the filters for the -vmodule syntax in value.
+func parseModuleSpec(value string) ([]modulePat, error) {
 	var filter []modulePat
 	for _, pat := range strings.Split(value, ",") {
 		if len(pat) == 0 {
@@ -287,15 +382,15 @@ func (m *moduleSpec) Set(value string) error {
 		}
 		patLev := strings.Split(pat, "=")
 		if len(patLev) != 2 || len(patLev[0]) == 0 || len(patLev[1]) == 0 {
-			return errVmoduleSyntax
+			return nil, errVmoduleSyntax
 		}
 		pattern := patLev[0]
 		v, err := strconv.Atoi(patLev[1])
 		if err != nil {
-			return errors.New("syntax error: expect comma-separated list of filename=N")
+			return nil, errors.New("syntax error: expect comma-separated list of filename=N")
 		}
 		if v < 0 {
-			return errors.New("negative value for vmodule level")
+			return nil, errors.New("negative value for vmodule level")
 		}
 		if v == 0 {
 			continue // Ignore. It's harmless but no point in paying the overhead.
@@ -303,10 +398,7 @@ func (m *moduleSpec) Set(value string) error {
 		// TODO: check syntax of filter?
 		filter = append(filter, modulePat{pattern, isLiteral(pattern), Level(v)})
 	}
-	logging.mu.Lock()
-	defer logging.mu.Unlock()
-	logging.setVState(logging.verbosity, filter, true)
-	return nil
+	return filter, nil
 }
 
 // isLiteral reports whether the pattern is a literal string, that is, has no metacharacters
@@ -328,7 +420,7 @@ func (t *traceLocation) isSet() bool {
 }
 
 // match reports whether the specified file and line matches the trace location.
-// The argument file name is the full path, not the basename specified in the flag.
+// The argument file name may be a path; only its basename is compared with the flag.
 // logging.mu is held.
 func (t *traceLocation) match(file string, line int) bool {
 	if t.line != line {
@@ -395,6 +487,29 @@ type flushSyncWriter interface {
 func init() {
 	flag.BoolVar(&logging.toStderr, "logtostderr", false, "log to standard error instead of files")
 	flag.BoolVar(&logging.alsoToStderr, "alsologtostderr", false, "log to standard error as well as files")
+	flag.StringVar(&logging.logDir, "log_dir", "", "If non-empty, write log files in this directory")
+	flag.BoolVar(&logging.dirFallback, "log_dir_fallback", true, "write log files in the temporary directory if -log_dir cannot be used")
+	flag.Var(&logging.format, "log_format", "format of log lines: text, logfmt or json, for stderr and the files or per destination such as stderr=logfmt,file=text")
+	flag.Var(&logging.filePath, "log_file_path", "form of the file in the header of log lines: base, package, module or func")
+	flag.Var(&logging.timeFormat, "log_time_format", "format of the time in the header of log lines: default (mmdd), year (yyyymmdd) or rfc3339")
+	flag.BoolVar(&logging.timeUTC, "log_time_utc", false, "write the time in the header of log lines in UTC instead of local time")
+	flag.BoolVar(&logging.timeNanos, "log_time_nanos", false, "write the time in the header of log lines with nanoseconds instead of microseconds")
+	flag.BoolVar(&logging.goroutineID, "log_goroutine_id", false, "write the goroutine ID instead of the process ID in the threadid column")
+	flag.Var(&logging.errorPolicy, "log_error_policy", "exit, stderr, drop or retry when a log file cannot be created or written")
+	flag.Var(&logging.durability, "log_durability", "comma-separated list of SEVERITY=buffered, flush or sync settings for records of that severity and above")
+	flag.Var(&logging.flushInterval, "log_flush_interval", "interval at which buffered log files are flushed")
+	flag.Var(&logging.bufferSize, "log_buffer_size", "size in bytes of the buffer of each log file")
+	flag.BoolVar(&logging.fileHeader, "log_file_header", true, "write a header with the creation time, host and binary at the start of each log file")
+	flag.Var(&logging.fileMode, "log_file_mode", "permission of new log files, before the umask")
+	flag.Var(&logging.dirMode, "log_dir_mode", "permission of a created -log_dir, before the umask")
+	flag.BoolVar(&logging.debugFiles, "log_debug_files", false, "write DEBUG and TRACE logs to their own files instead of the INFO file")
+	flag.Var(&logging.rotateInterval, "log_rotate_interval", "also rotate log files hourly, daily or at this duration, aligned to the clock")
+	flag.IntVar(&logging.retention.maxFiles, "log_max_files", 0, "if positive, the maximum number of log files to keep per severity")
+	flag.DurationVar(&logging.retention.maxAge, "log_max_age", 0, "if positive, log files older than this are deleted")
+	flag.Uint64Var(&logging.retention.maxTotalSize, "log_max_total_size", 0, "if positive, the maximum size in bytes of all log files per severity")
+	flag.BoolVar(&logging.compress, "log_compress", false, "gzip log files after rotation")
+	flag.Var(&logging.fileMap, "log_files", "single, single+error or comma-separated SEVERITY=FILE+FILE settings to map severities to log files")
+	flag.Var(&logging.fileTemplate, "log_file_template", "template for log file names, such as {program}-{tag}-{date}.{seq}.log")
 	flag.Var(&logging.verbosity, "v", "log level for V logs")
 	flag.Var(&logging.stderrThreshold, "stderrthreshold", "logs at or above this threshold go to stderr")
 	flag.Var(&logging.vmodule, "vmodule", "comma-separated list of pattern=N settings for file-filtered logging")
@@ -402,14 +517,22 @@ func init() {
 
 	// Default stderrThreshold is ERROR.
 	logging.stderrThreshold = errorLog
+	logging.flushInterval.changed = make(chan struct{}, 1)
+	logging.flushInterval.set(defaultFlushInterval)
+	logging.bufferSize = defaultBufferSize
+	logging.fileHeader = true
+	logging.fileMode = 0666
+	logging.dirMode = 0755
 
 	logging.setVState(0, nil, false)
+	logging.sinks = []Sink{&stderrSink{&logging}, &fileSink{&logging}}
 	go logging.flushDaemon()
 }
 
-// Flush flushes all pending log I/O.
+// Flush flushes all pending log I/O of the default Logger.
 func Flush() {
 	logging.lockAndFlushAll()
+	logging.waitSinks(asyncFlushTimeout)
 }
 
 // loggingT collects all the global state of the logging setup.
@@ -423,6 +546,73 @@ type loggingT struct {
 	// Level flag. Handled atomically.
 	stderrThreshold severity // The -stderrthreshold flag.
 
+	// If non-empty, overrides the choice of directory in which to write logs.
+	// See createLogDirs for the full list of possible destinations.
+	logDir string // The -log_dir flag.
+	// debugFiles is the -log_debug_files flag. If set, DEBUG and TRACE
+	// records have their own files; otherwise they go to the INFO file.
+	debugFiles bool
+	// rotateInterval is the -log_rotate_interval flag; files are also
+	// rotated at multiples of it since midnight.
+	rotateInterval rotateInterval
+	// fileMap is the -log_files flag; see appendFileTargets.
+	fileMap fileMap
+	// fileTemplate is the -log_file_template flag.
+	fileTemplate fileTemplate
+	// retention limits the files kept per severity; see removeOldFiles.
+	// retentionMu serializes the removal of old files.
+	retention   retention
+	retentionMu sync.Mutex
+	// compress is the -log_compress flag. Rotated files are compressed with
+	// compressor, or gzip if it is nil. compressing holds the names of the
+	// files being compressed and their compressed forms.
+	compress      bool
+	compressor    Compressor
+	compressing   map[string]bool
+	compressingMu sync.Mutex
+	// background tracks the goroutines that compress and remove old files.
+	background sync.WaitGroup
+	// format is the -log_format flag.
+	format formatMap
+	// filePath is the -log_file_path flag.
+	filePath filePath
+	// timeFormat, timeUTC and timeNanos are the -log_time_format, -log_time_utc
+	// and -log_time_nanos flags for the time in the header.
+	timeFormat timeFormat
+	timeUTC    bool
+	timeNanos  bool
+	// goroutineID is the -log_goroutine_id flag.
+	goroutineID bool
+	// errorPolicy is the -log_error_policy flag; onError is set by SetOnError.
+	errorPolicy ErrorPolicy
+	onError     func(err error)
+	// createRetryAt is the time after an error creating log files to try again,
+	// after createAttempts failed attempts of RetryOnError.
+	createRetryAt  time.Time
+	createAttempts int
+	// durability is the -log_durability flag.
+	durability durabilityMap
+	// flushInterval is the -log_flush_interval flag, used by the flushDaemon.
+	flushInterval interval
+	// bufferSize is the -log_buffer_size flag, used for new log files.
+	bufferSize byteCount
+	// fileHeader is the -log_file_header flag; see AddFileHeader.
+	fileHeader bool
+	// fileMode and dirMode are the -log_file_mode and -log_dir_mode flags.
+	fileMode fileMode
+	dirMode  fileMode
+	// dirFallback is the -log_dir_fallback flag. If not set, files are
+	// only created in logDir and not in os.TempDir().
+	dirFallback bool
+	// logDirs lists the candidate directories for new log files.
+	logDirs []string
+	// logDirsErr is the error, if any, of computing logDirs.
+	logDirsErr error
+	// onceLogDirs computes logDirs when the first log file is created.
+	onceLogDirs sync.Once
+	// done stops the flushDaemon when closed.
+	done chan struct{}
+
 	// freeList is a list of byte buffers, maintained under freeListMu.
 	freeList *buffer
 	// freeListMu maintains the free list. It is separate from the main mutex
@@ -435,6 +625,8 @@ type loggingT struct {
 	mu sync.Mutex
 	// file holds writer for each of the log types.
 	file [numSeverity]flushSyncWriter
+	// sinks holds the destinations that receive every record, in order of registration.
+	sinks []Sink
 	// pcs is used in V to avoid an allocation when computing the caller's PC.
 	pcs [1]uintptr
 	// vmap is a cache of the V Level for each V() call site, identified by PC.
@@ -459,26 +651,27 @@ type buffer struct {
 	next *buffer
 }
 
+// logging is the state of the default Logger used by the package-level functions.
 var logging loggingT
 
 // setVState sets a consistent state for V logging.
 // l.mu is held.
 func (l *loggingT) setVState(verbosity Level, filter []modulePat, setFilter bool) {
 	// Turn verbosity off so V will not fire while we are in transition.
-	logging.verbosity.set(0)
+	l.verbosity.set(0)
 	// Ditto for filter length.
-	logging.filterLength = 0
+	l.filterLength = 0
 
 	// Set the new filters and wipe the pc->Level map if the filter has changed.
 	if setFilter {
-		logging.vmodule.filter = filter
-		logging.vmap = make(map[uintptr]Level)
+		l.vmodule.filter = filter
+		l.vmap = make(map[uintptr]Level)
 	}
 
 	// Things are consistent now, so enable filtering and verbosity.
 	// They are enabled in order opposite to that in V.
-	atomic.StoreInt32(&logging.filterLength, int32(len(filter)))
-	logging.verbosity.set(verbosity)
+	atomic.StoreInt32(&l.filterLength, int32(len(filter)))
+	l.verbosity.set(verbosity)
 }
 
 // getBuffer returns a new, ready-to-use buffer.
@@ -514,12 +707,14 @@ var timeNow = time.Now // Stubbed out for testing.
 
 /*
 header formats a log header as defined by the C++ implementation.
-It returns a buffer containing the formatted header.
+It returns a buffer containing the formatted header and the record
+describing the log event. The depth is the number of stack frames
+to skip beyond the caller of the exported logging function.
 
 Log lines have this form:
 	Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg...
 where the fields are defined as follows:
-	L                A single character, representing the log level (eg 'I' for INFO)
+	L                A single character, representing the log level (eg 'I' for INFO, 'D' for DEBUG)
 	mm               The month (zero padded; ie May is '05')
 	dd               The day (zero padded)
 	hh:mm:ss.uuuuuu  Time in hours, minutes and fractional seconds
@@ -527,50 +722,74 @@ where the fields are defined as follows:
 	file             The file name
 	line             The line number
 	msg              The user-supplied message
+See -log_time_format, -log_time_utc and -log_time_nanos for other formats of the time.
 */
-func (l *loggingT) header(s severity) *buffer {
+func (l *loggingT) header(s severity, depth int) (*buffer, *Record) {
 	// Lmmdd hh:mm:ss.uuuuuu threadid file:line]
 	now := timeNow()
-	_, file, line, ok := runtime.Caller(3) // It's always the same number of frames to the user's call.
-	if !ok {
-		file = "???"
-		line = 1
-	} else {
-		slash := strings.LastIndex(file, "/")
-		if slash >= 0 {
-			file = file[slash+1:]
+	var file, base string
+	var line int
+	if l.filePath == basePath {
+		var ok bool
+		_, file, line, ok = runtime.Caller(3 + depth) // It's always the same number of frames to the user's call.
+		if !ok {
+			file = "???"
+			line = 1
+		} else {
+			slash := strings.LastIndex(file, "/")
+			if slash >= 0 {
+				file = file[slash+1:]
+			}
 		}
+		base = file
+	} else {
+		file, base, line = callerPath(l.filePath, 4+depth)
 	}
 	if line < 0 {
 		line = 0 // not a real line number, but acceptable to someDigits
 	}
-	if s > fatalLog {
+	if s < traceLog || s > fatalLog {
 		s = infoLog // for safety.
 	}
+	tid := pid // TODO: should be TID
+	if l.goroutineID {
+		tid = goroutineID()
+	}
+	r := &Record{
+		Time:     now,
+		Severity: severityName[s],
+		ThreadID: tid,
+		File:     file,
+		Line:     line,
+		sev:      s,
+		base:     base,
+	}
+	buf := l.formatHeader(r)
+	r.hlen = buf.Len()
+	return buf, r
+}
+
+// formatHeader formats a log header using the time, severity and location of the record.
+func (l *loggingT) formatHeader(r *Record) *buffer {
 	buf := l.getBuffer()
 
 	// Avoid Fprintf, for speed. The format is so simple that we can do it quickly by hand.
 	// It's worth about 3X. Fprintf is hard.
-	_, month, day := now.Date()
-	hour, minute, second := now.Clock()
-	buf.tmp[0] = severityChar[s]
-	buf.twoDigits(1, int(month))
-	buf.twoDigits(3, day)
-	buf.tmp[5] = ' '
-	buf.twoDigits(6, hour)
-	buf.tmp[8] = ':'
-	buf.twoDigits(9, minute)
-	buf.tmp[11] = ':'
-	buf.twoDigits(12, second)
-	buf.tmp[14] = '.'
-	buf.nDigits(6, 15, now.Nanosecond()/1000)
-	buf.tmp[21] = ' '
-	buf.nDigits(5, 22, pid) // TODO: should be TID
-	buf.tmp[27] = ' '
-	buf.Write(buf.tmp[:28])
-	buf.WriteString(file)
+	buf.tmp[0] = severityChar[r.sev]
+	i := l.formatTime(buf, 1, r.Time)
+	buf.tmp[i] = ' '
+	i++
+	if r.ThreadID < 100000 {
+		buf.nDigits(5, i, r.ThreadID)
+		i += 5
+	} else {
+		i += buf.someDigits(i, r.ThreadID)
+	}
+	buf.tmp[i] = ' '
+	buf.Write(buf.tmp[:i+1])
+	buf.WriteString(r.File)
 	buf.tmp[0] = ':'
-	n := buf.someDigits(1, line)
+	n := buf.someDigits(1, r.Line)
 	buf.tmp[n+1] = ']'
 	buf.tmp[n+2] = ' '
 	buf.Write(buf.tmp[:n+3])
@@ -612,107 +831,86 @@ func (buf *buffer) someDigits(i, d int) int {
 	return copy(buf.tmp[i:], buf.tmp[j:])
 }
 
-func (l *loggingT) println(s severity, args ...interface{}) {
-	buf := l.header(s)
+func (lg *Logger) println(s severity, depth int, args ...interface{}) {
+	buf, r := lg.l.header(s, depth)
 	fmt.Fprintln(buf, args...)
-	l.output(s, buf)
+	lg.emit(r, buf)
 }
 
-func (l *loggingT) print(s severity, args ...interface{}) {
-	buf := l.header(s)
+func (lg *Logger) print(s severity, depth int, args ...interface{}) {
+	buf, r := lg.l.header(s, depth)
 	fmt.Fprint(buf, args...)
-	if buf.Bytes()[buf.Len()-1] != '\n' {
-		buf.WriteByte('\n')
-	}
-	l.output(s, buf)
+	lg.emit(r, buf)
 }
 
-func (l *loggingT) printf(s severity, format string, args ...interface{}) {
-	buf := l.header(s)
+func (lg *Logger) printf(s severity, depth int, format string, args ...interface{}) {
+	buf, r := lg.l.header(s, depth)
 	fmt.Fprintf(buf, format, args...)
-	if buf.Bytes()[buf.Len()-1] != '\n' {
-		buf.WriteByte('\n')
+	lg.emit(r, buf)
+}
+
+// emit completes the record with the message and the fields of the Logger
+// and the record, then outputs it. The buffer holds the header and the
+// message; a newline is appended if missing.
+func (lg *Logger) emit(r *Record, buf *buffer) {
+	if buf.Len() > r.hlen && buf.Bytes()[buf.Len()-1] == '\n' {
+		buf.Truncate(buf.Len() - 1)
+	}
+	r.Message = string(buf.Bytes()[r.hlen:])
+	buf.Write(lg.text)
+	writeFields(&buf.Buffer, r.Fields)
+	buf.WriteByte('\n')
+	if n := len(lg.fields); n > 0 {
+		r.Fields = append(lg.fields[:n:n], r.Fields...)
 	}
-	l.output(s, buf)
+	lg.l.output(r, buf)
 }
 
-// output writes the data to the log files and releases the buffer.
-func (l *loggingT) output(s severity, buf *buffer) {
+// output hands the completed record to all sinks and releases the buffer.
+func (l *loggingT) output(r *Record, buf *buffer) {
+	s := r.sev
 	l.mu.Lock()
-	if l.traceLocation.isSet() {
-		_, file, line, ok := runtime.Caller(3) // It's always the same number of frames to the user's call (same as header).
-		if ok && l.traceLocation.match(file, line) {
-			buf.Write(stacks(false))
-		}
+	if l.traceLocation.isSet() && l.traceLocation.match(r.base, r.Line) {
+		r.trace = stacks(false)
+		buf.Write(r.trace)
 	}
-	data := buf.Bytes()
-	// if logstash is enabled and severity is not fatal then write the data to it
-	if logstash.toLogstash && s != fatalLog {
-		logstash.WriteWithStack(data, nil) // without stack
+	r.Data = buf.Bytes()
+	if s == fatalLog {
+		// Write the stack trace for all goroutines to the sinks.
+		r.Stack = stacks(true)
+		logExitFunc = func(error) {} // If we get a write error, we'll still exit below.
 	}
-	if l.toStderr {
-		os.Stderr.Write(data)
-	} else {
-		if l.alsoToStderr || s >= l.stderrThreshold.get() {
-			os.Stderr.Write(data)
-		}
-		if l.file[s] == nil {
-			if err := l.createFiles(s); err != nil {
-				os.Stderr.Write(data) // Make sure the message appears somewhere.
-				l.exit(err)
-			}
-		}
-		switch s {
-		case fatalLog:
-			l.file[fatalLog].Write(data)
-			fallthrough
-		case errorLog:
-			l.file[errorLog].Write(data)
-			fallthrough
-		case warningLog:
-			l.file[warningLog].Write(data)
-			fallthrough
-		case infoLog:
-			l.file[infoLog].Write(data)
+	for _, sink := range l.sinks {
+		if err := sink.Emit(r); err != nil {
+			fmt.Fprintf(os.Stderr, "[glog error] sink failed to emit record: %v\n", err)
 		}
 	}
 	if s == fatalLog {
-		// Make sure we see the trace for the current goroutine on standard error.
-		if !l.toStderr {
-			os.Stderr.Write(stacks(false))
-		}
-		// Write the stack trace for all goroutines to the files.
-		trace := stacks(true)
-		// if logstash is enabled and setup then write the data and stack to it
-		if logstash.toLogstash {
-			logstash.WriteWithStack(data, trace)
-		}
-		logExitFunc = func(error) {} // If we get a write error, we'll still exit below.
-		for log := fatalLog; log >= infoLog; log-- {
-			if f := l.file[log]; f != nil { // Can be nil if -logtostderr is set.
-				f.Write(trace)
-			}
-		}
 		l.mu.Unlock()
-		timeoutFlush(10 * time.Second)
+		l.timeoutFlush(10 * time.Second)
+		l.waitSinks(asyncFlushTimeout)
 		os.Exit(255) // C++ uses -1, which is silly because it's anded with 255 anyway.
 	}
+	n := len(l.data(r, l.statsFormat()))
 	l.putBuffer(buf)
+	if r.encoded != nil {
+		l.putBuffer(r.encoded)
+	}
 	l.mu.Unlock()
 	if stats := severityStats[s]; stats != nil {
 		atomic.AddInt64(&stats.lines, 1)
-		atomic.AddInt64(&stats.bytes, int64(len(data)))
+		atomic.AddInt64(&stats.bytes, int64(n))
 	}
 }
 
-// timeoutFlush calls Flush and returns when it completes or after timeout
+// timeoutFlush calls lockAndFlushAll and returns when it completes or after timeout
 // elapses, whichever happens first.  This is needed because the hooks invoked
 // by Flush may deadlock when glog.Fatal is called from a hook that holds
 // a lock.
-func timeoutFlush(timeout time.Duration) {
+func (l *loggingT) timeoutFlush(timeout time.Duration) {
 	done := make(chan bool, 1)
 	go func() {
-		Flush() // calls logging.lockAndFlushAll()
+		l.lockAndFlushAll()
 		done <- true
 	}()
 	select {
@@ -758,6 +956,7 @@ func (l *loggingT) exit(err error) {
 		return
 	}
 	l.flushAll()
+	waitAsync(l.sinks, asyncFlushTimeout) // holding l.mu does not matter when exiting
 	os.Exit(2)
 }
 
@@ -768,85 +967,162 @@ func (l *loggingT) exit(err error) {
 type syncBuffer struct {
 	logger *loggingT
 	*bufio.Writer
-	file   *os.File
-	sev    severity
-	nbytes uint64 // The number of bytes written to this file
+	file     *os.File
+	sev      severity
+	nbytes   uint64    // The number of bytes written to this file
+	rotateAt time.Time // The time at which to rotate the file; zero if not rotated by time.
+	err      error     // The last error creating or writing the file, if not recovered.
+	retryAt  time.Time // The time after err at which to create a new file.
+	attempts int       // The number of failed attempts of RetryOnError to create a new file.
 }
 
 func (sb *syncBuffer) Sync() error {
 	return sb.file.Sync()
 }
 
+// Write writes p, rotating the file when needed. Errors are handled by
+// fileSink.Emit according to the -log_error_policy; after an error a new
+// file is created once sb.retryAt has passed.
 func (sb *syncBuffer) Write(p []byte) (n int, err error) {
-	if sb.nbytes+uint64(len(p)) >= MaxSize {
-		if err := sb.rotateFile(time.Now()); err != nil {
-			sb.logger.exit(err)
+	now := timeNow()
+	if sb.err != nil && now.Before(sb.retryAt) {
+		return 0, errCreateLater
+	}
+	if sb.err != nil || sb.nbytes+uint64(len(p)) >= MaxSize || !sb.rotateAt.IsZero() && !now.Before(sb.rotateAt) {
+		if err := sb.rotateFile(now); err != nil {
+			return 0, sb.failed(err, now)
 		}
+		sb.err, sb.attempts = nil, 0
 	}
 	n, err = sb.Writer.Write(p)
 	sb.nbytes += uint64(n)
+	if err != nil && sb.logger.errorPolicy.get() == RetryOnError {
+		// Write the record again to a new file; bufio keeps its error.
+		if err = sb.rotateFile(now); err == nil {
+			n, err = sb.Writer.Write(p)
+			sb.nbytes += uint64(n)
+		}
+	}
 	if err != nil {
-		sb.logger.exit(err)
+		return n, sb.failed(err, now)
 	}
 	return
 }
 
 // rotateFile closes the syncBuffer's file and starts a new one.
 func (sb *syncBuffer) rotateFile(now time.Time) error {
+	var rotated string
 	if sb.file != nil {
 		sb.Flush()
-		sb.file.Close()
+		sb.closeFile()
+		rotated = sb.file.Name()
 	}
 	var err error
-	sb.file, _, err = create(severityName[sb.sev], now)
+	var fname string
+	sb.file, fname, err = sb.logger.create(severityName[sb.sev], now, rotated)
+	if rotated != "" && rotated != fname { // not created again under the same name
+		sb.logger.compressFile(rotated)
+	}
 	sb.nbytes = 0
+	sb.rotateAt = nextRotation(now, time.Duration(sb.logger.rotateInterval))
 	if err != nil {
 		return err
 	}
 
-	sb.Writer = bufio.NewWriterSize(sb.file, bufferSize)
+	trackFile(fname, 1)
+	sb.Writer = bufio.NewWriterSize(sb.file, int(sb.logger.bufferSize))
+	if info, err := sb.file.Stat(); err == nil {
+		sb.nbytes = uint64(info.Size()) // appended to
+	}
+	if sb.nbytes == 0 {
+		err = sb.writeHeader(now)
+	}
+	sb.logger.removeOldFiles(severityName[sb.sev], now)
+	return err
+}
 
-	// Write header.
+// closeFile closes the file, which retention no longer keeps for this process.
+func (sb *syncBuffer) closeFile() {
+	sb.file.Close()
+	trackFile(sb.file.Name(), -1)
+}
+
+// writeHeader writes the header of a new log file directly to the file,
+// unless -log_file_header is false.
+func (sb *syncBuffer) writeHeader(now time.Time) error {
+	if !sb.logger.fileHeader {
+		return nil
+	}
 	var buf bytes.Buffer
 	fmt.Fprintf(&buf, "Log file created at: %s\n", now.Format("2006/01/02 15:04:05"))
 	fmt.Fprintf(&buf, "Running on machine: %s\n", host)
 	fmt.Fprintf(&buf, "Binary: Built with %s %s for %s/%s\n", runtime.Compiler, runtime.Version(), runtime.GOOS, runtime.GOARCH)
-	fmt.Fprintf(&buf, "Log line format: [IWEF]mmdd hh:mm:ss.uuuuuu threadid file:line] msg\n")
+	fmt.Fprintf(&buf, "Log line format: %s\n", sb.logger.fileLineFormat())
+	writeFileHeaders(&buf)
 	n, err := sb.file.Write(buf.Bytes())
 	sb.nbytes += uint64(n)
 	return err
 }
 
-// bufferSize sizes the buffer associated with each log file. It's large
+// defaultBufferSize sizes the buffer associated with each log file. It's large
 // so that log records can accumulate without the logging thread blocking
-// on disk I/O. The flushDaemon will block instead.
-const bufferSize = 256 * 1024
+// on disk I/O. The flushDaemon will block instead. See -log_buffer_size.
+const defaultBufferSize = 256 * 1024
 
-// createFiles creates all the log files for severity from sev down to infoLog.
+// createFiles creates the missing log files of the severities.
 // l.mu is held.
-func (l *loggingT) createFiles(sev severity) error {
-	now := time.Now()
-	// Files are created in decreasing severity order, so as soon as we find one
-	// has already been created, we can stop.
-	for s := sev; s >= infoLog && l.file[s] == nil; s-- {
+// After an error, it returns errCreateLater until recoverInterval has passed.
+func (l *loggingT) createFiles(severities []severity) error {
+	now := time.Time{}
+	for _, s := range severities {
+		if l.file[s] != nil {
+			continue
+		}
+		if now.IsZero() {
+			now = timeNow()
+			if now.Before(l.createRetryAt) {
+				return errCreateLater
+			}
+		}
 		sb := &syncBuffer{
 			logger: l,
 			sev:    s,
 		}
 		if err := sb.rotateFile(now); err != nil {
+			if l.errorPolicy.get() == ExitOnError {
+				return err
+			}
+			var delay time.Duration
+			delay, l.createAttempts = l.retryDelay(l.createAttempts)
+			l.createRetryAt = now.Add(delay)
+			if l.createAttempts > 0 {
+				return errCreateLater
+			}
 			return err
 		}
+		l.createAttempts = 0
 		l.file[s] = sb
 	}
 	return nil
 }
 
-const flushInterval = 30 * time.Second
+// defaultFlushInterval is the default of -log_flush_interval.
+const defaultFlushInterval = 30 * time.Second
 
-// flushDaemon periodically flushes the log file buffers.
+// flushDaemon periodically flushes the log file buffers until l.done is closed.
+// A change of the flush interval, such as by flag.Parse, restarts the ticker.
 func (l *loggingT) flushDaemon() {
-	for _ = range time.NewTicker(flushInterval).C {
-		l.lockAndFlushAll()
+	ticker := time.NewTicker(l.flushInterval.get())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.lockAndFlushAll()
+		case <-l.flushInterval.changed:
+			ticker.Reset(l.flushInterval.get())
+		case <-l.done:
+			return
+		}
 	}
 }
 
@@ -860,17 +1136,8 @@ func (l *loggingT) lockAndFlushAll() {
 // flushAll flushes all the logs and attempts to "sync" their data to disk.
 // l.mu is held.
 func (l *loggingT) flushAll() {
-	// Flush from fatal down, in case there's trouble flushing.
-	for s := fatalLog; s >= infoLog; s-- {
-		file := l.file[s]
-		if file != nil {
-			file.Flush() // ignore error
-			file.Sync()  // ignore error
-		}
-	}
-	// flush pending logstash messages
-	if logstash.toLogstash {
-		logstash.flush()
+	for _, sink := range l.sinks {
+		sink.Flush() // ignore error
 	}
 }
 
@@ -919,39 +1186,59 @@ type Verbose bool
 // V is at least the value of -v, or of -vmodule for the source file containing the
 // call, the V call will log.
 func V(level Level) Verbose {
+	return Verbose(logging.v(level, 0))
+}
+
+// VDepth is like V but uses the call site depth frames above the caller of VDepth
+// to look up the -vmodule level. VDepth(0, level) is equivalent to V(level).
+func VDepth(depth int, level Level) Verbose {
+	return Verbose(logging.v(level, depth))
+}
+
+// v reports whether verbosity at the call site of the caller is at least the requested level.
+// The depth is the number of stack frames to skip beyond that caller.
+func (l *loggingT) v(level Level, depth int) bool {
 	// This function tries hard to be cheap unless there's work to do.
 	// The fast path is two atomic loads and compares.
 
 	// Here is a cheap but safe test to see if V logging is enabled globally.
-	if logging.verbosity.get() >= level {
-		return Verbose(true)
+	if l.verbosity.get() >= level {
+		return true
 	}
 
 	// It's off globally but it vmodule may still be set.
 	// Here is another cheap but safe test to see if vmodule is enabled.
-	if atomic.LoadInt32(&logging.filterLength) > 0 {
+	if atomic.LoadInt32(&l.filterLength) > 0 {
 		// Now we need a proper lock to use the logging structure. The pcs field
 		// is shared so we must lock before accessing it. This is fairly expensive,
 		// but if V logging is enabled we're slow anyway.
-		logging.mu.Lock()
-		defer logging.mu.Unlock()
-		if runtime.Callers(2, logging.pcs[:]) == 0 {
-			return Verbose(false)
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if runtime.Callers(3+depth, l.pcs[:]) == 0 {
+			return false
 		}
-		v, ok := logging.vmap[logging.pcs[0]]
+		v, ok := l.vmap[l.pcs[0]]
 		if !ok {
-			v = logging.setV(logging.pcs[0])
+			v = l.setV(l.pcs[0])
 		}
-		return Verbose(v >= level)
+		return v >= level
 	}
-	return Verbose(false)
+	return false
 }
 
 // Info is equivalent to the global Info function, guarded by the value of v.
 // See the documentation of V for usage.
 func (v Verbose) Info(args ...interface{}) {
 	if v {
-		logging.print(infoLog, args...)
+		defaultLogger.print(infoLog, 0, args...)
+	}
+}
+
+// InfoDepth is equivalent to the global InfoDepth function, guarded by the value of v.
+// See the documentation of V for usage.
+func (v Verbose) InfoDepth(depth int, args ...interface{}) {
+	if v {
+		defaultLogger.print(infoLog, depth, args...)
 	}
 }
 
@@ -959,7 +1246,7 @@ func (v Verbose) Info(args ...interface{}) {
 // See the documentation of V for usage.
 func (v Verbose) Infoln(args ...interface{}) {
 	if v {
-		logging.println(infoLog, args...)
+		defaultLogger.println(infoLog, 0, args...)
 	}
 }
 
@@ -967,81 +1254,105 @@ func (v Verbose) Infoln(args ...interface{}) {
 // See the documentation of V for usage.
 func (v Verbose) Infof(format string, args ...interface{}) {
 	if v {
-		logging.printf(infoLog, format, args...)
+		defaultLogger.printf(infoLog, 0, format, args...)
 	}
 }
 
 // Info logs to the INFO log.
 // Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
 func Info(args ...interface{}) {
-	logging.print(infoLog, args...)
+	defaultLogger.print(infoLog, 0, args...)
+}
+
+// InfoDepth acts as Info but uses depth to determine which call frame to log.
+// InfoDepth(0, "msg") is the same as Info("msg").
+func InfoDepth(depth int, args ...interface{}) {
+	defaultLogger.print(infoLog, depth, args...)
 }
 
 // Infoln logs to the INFO log.
 // Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
 func Infoln(args ...interface{}) {
-	logging.println(infoLog, args...)
+	defaultLogger.println(infoLog, 0, args...)
 }
 
 // Infof logs to the INFO log.
 // Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
 func Infof(format string, args ...interface{}) {
-	logging.printf(infoLog, format, args...)
+	defaultLogger.printf(infoLog, 0, format, args...)
 }
 
 // Warning logs to the WARNING and INFO logs.
 // Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
 func Warning(args ...interface{}) {
-	logging.print(warningLog, args...)
+	defaultLogger.print(warningLog, 0, args...)
+}
+
+// WarningDepth acts as Warning but uses depth to determine which call frame to log.
+// WarningDepth(0, "msg") is the same as Warning("msg").
+func WarningDepth(depth int, args ...interface{}) {
+	defaultLogger.print(warningLog, depth, args...)
 }
 
 // Warningln logs to the WARNING and INFO logs.
 // Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
 func Warningln(args ...interface{}) {
-	logging.println(warningLog, args...)
+	defaultLogger.println(warningLog, 0, args...)
 }
 
 // Warningf logs to the WARNING and INFO logs.
 // Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
 func Warningf(format string, args ...interface{}) {
-	logging.printf(warningLog, format, args...)
+	defaultLogger.printf(warningLog, 0, format, args...)
 }
 
 // Error logs to the ERROR, WARNING, and INFO logs.
 // Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
 func Error(args ...interface{}) {
-	logging.print(errorLog, args...)
+	defaultLogger.print(errorLog, 0, args...)
+}
+
+// ErrorDepth acts as Error but uses depth to determine which call frame to log.
+// ErrorDepth(0, "msg") is the same as Error("msg").
+func ErrorDepth(depth int, args ...interface{}) {
+	defaultLogger.print(errorLog, depth, args...)
 }
 
 // Errorln logs to the ERROR, WARNING, and INFO logs.
 // Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
 func Errorln(args ...interface{}) {
-	logging.println(errorLog, args...)
+	defaultLogger.println(errorLog, 0, args...)
 }
 
 // Errorf logs to the ERROR, WARNING, and INFO logs.
 // Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
 func Errorf(format string, args ...interface{}) {
-	logging.printf(errorLog, format, args...)
+	defaultLogger.printf(errorLog, 0, format, args...)
 }
 
 // Fatal logs to the FATAL, ERROR, WARNING, and INFO logs,
 // including a stack trace of all running goroutines, then calls os.Exit(255).
 // Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
 func Fatal(args ...interface{}) {
-	logging.print(fatalLog, args...)
+	defaultLogger.print(fatalLog, 0, args...)
+}
+
+// FatalDepth acts as Fatal but uses depth to determine which call frame to log.
+// FatalDepth(0, "msg") is the same as Fatal("msg").
+func FatalDepth(depth int, args ...interface{}) {
+	defaultLogger.print(fatalLog, depth, args...)
 }
 
 // Fatalln logs to the FATAL, ERROR, WARNING, and INFO logs,
 // including a stack trace of all running goroutines, then calls os.Exit(255).
 // Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
 func Fatalln(args ...interface{}) {
-	logging.println(fatalLog, args...)
+	defaultLogger.println(fatalLog, 0, args...)
 }
 
 // Fatalf logs to the FATAL, ERROR, WARNING, and INFO logs,
 // including a stack trace of all running goroutines, then calls os.Exit(255).
 // Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
 func Fatalf(format string, args ...interface{}) {
-	logging.printf(fatalLog, format, args...)
+	defaultLogger.printf(fatalLog, 0, format, args...)
 }
@@ -0,0 +1,158 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Forms of the file:line location of the logging call.
+
+package glog
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// filePath is the form of the file in the header and the Record.
+// It is the type of the -log_file_path flag.
+type filePath int8
+
+const (
+	basePath    filePath = iota // handler.go, as the C++ library.
+	packagePath                 // github.com/org/app/pkg/sub/handler.go
+	modulePath                  // pkg/sub/handler.go, relative to the main module.
+	funcPath                    // sub.(*Server).Handle
+)
+
+var filePathName = []string{
+	basePath:    "base",
+	packagePath: "package",
+	modulePath:  "module",
+	funcPath:    "func",
+}
+
+func (p *filePath) String() string {
+	return filePathName[*p]
+}
+
+// Get is part of the flag.Value interface.
+func (p *filePath) Get() interface{} {
+	return *p
+}
+
+// Set is part of the flag.Value interface.
+func (p *filePath) Set(value string) error {
+	parsed, err := parseFilePath(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	*p = parsed
+	return nil
+}
+
+// parseFilePath parses "base", "package", "module" or "func". The empty string is "base".
+func parseFilePath(value string) (filePath, error) {
+	if value == "" {
+		return basePath, nil
+	}
+	for i, name := range filePathName {
+		if value == name {
+			return filePath(i), nil
+		}
+	}
+	return 0, fmt.Errorf("log: unknown file path %q: want base, package, module or func", value)
+}
+
+// mainModule is the path of the main module, such as "github.com/org/app", and
+// mainPackage the path of the main package, such as "github.com/org/app/cmd/tool", if known.
+var (
+	mainModuleOnce sync.Once
+	mainModule     string
+	mainPackage    string
+)
+
+func readBuildInfo() {
+	mainModuleOnce.Do(func() {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			mainModule, mainPackage = info.Main.Path, info.Path
+		}
+	})
+}
+
+func mainModulePath() string {
+	readBuildInfo()
+	return mainModule
+}
+
+// importPath returns the import path of the package pkg of a function name,
+// which is "main" for any command.
+func importPath(pkg string) string {
+	if readBuildInfo(); pkg == "main" && mainPackage != "" {
+		return mainPackage
+	}
+	return pkg
+}
+
+// callerPath returns the location of the caller skip frames up, as for
+// runtime.Callers, in the form of mode, and the base name of its file.
+func callerPath(mode filePath, skip int) (file, base string, line int) {
+	var pcs [1]uintptr
+	if runtime.Callers(skip+1, pcs[:]) == 0 {
+		return "???", "???", 1
+	}
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	base, line = frame.File, frame.Line
+	if slash := strings.LastIndex(base, "/"); slash >= 0 {
+		base = base[slash+1:]
+	}
+	pkg, function := splitFunction(frame.Function)
+	pkg = importPath(pkg)
+	switch {
+	case frame.Function == "":
+		return base, base, line
+	case mode == funcPath:
+		return function, base, line
+	case mode == modulePath:
+		if module := mainModulePath(); module != "" && strings.HasPrefix(pkg, module+"/") {
+			return pkg[len(module)+1:] + "/" + base, base, line
+		}
+		if module := mainModulePath(); module != "" && pkg == module {
+			return base, base, line
+		}
+	}
+	return pkg + "/" + base, base, line
+}
+
+// splitFunction splits a function name such as "github.com/org/app/sub.(*Server).Handle"
+// into the package path "github.com/org/app/sub" and "sub.(*Server).Handle".
+// The runtime escapes dots in the last element of the package path, as in
+// "gopkg.in/yaml%2ev3.(*Decoder).Decode", so the first dot ends the path.
+func splitFunction(name string) (pkg, function string) {
+	slash := strings.LastIndex(name, "/")
+	function = name[slash+1:]
+	if dot := strings.IndexByte(function, '.'); dot >= 0 {
+		pkg = name[:slash+1+dot]
+	} else {
+		pkg = name
+	}
+	return unescapeDots(pkg), unescapeDots(function)
+}
+
+func unescapeDots(s string) string {
+	return strings.Replace(s, "%2e", ".", -1)
+}
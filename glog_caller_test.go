@@ -0,0 +1,126 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSplitFunction(t *testing.T) {
+	for _, each := range []struct{ name, pkg, function string }{
+		{"github.com/org/app/sub.(*Server).Handle", "github.com/org/app/sub", "sub.(*Server).Handle"},
+		{"github.com/org/app/sub.Handle.func1", "github.com/org/app/sub", "sub.Handle.func1"},
+		{"main.main", "main", "main.main"},
+		{"main.(*server).run.func1", "main", "main.(*server).run.func1"},
+		{"gopkg.in/yaml%2ev3.(*Decoder).Decode", "gopkg.in/yaml.v3", "yaml.v3.(*Decoder).Decode"},
+		{"example.com/m.v3/api.v1.Get", "example.com/m.v3/api", "api.v1.Get"}, // a method of type v1
+		{"example.com/m.v3/api.(*v1).Get", "example.com/m.v3/api", "api.(*v1).Get"},
+		{"github.com/org/app/v2.Run", "github.com/org/app/v2", "v2.Run"},
+	} {
+		pkg, function := splitFunction(each.name)
+		if pkg != each.pkg || function != each.function {
+			t.Errorf("%s: got %q %q want %q %q", each.name, pkg, function, each.pkg, each.function)
+		}
+	}
+}
+
+// v1 is a type named like a major version suffix.
+type v1 struct{}
+
+func (v1) function() string {
+	pc, _, _, _ := runtime.Caller(0)
+	return runtime.FuncForPC(pc).Name()
+}
+
+func TestSplitFunctionVersionType(t *testing.T) {
+	pkg, function := splitFunction(v1{}.function())
+	if want := pkg[strings.LastIndex(pkg, "/")+1:] + ".v1.function"; strings.HasSuffix(pkg, ".v1") || function != want {
+		t.Errorf("got %q %q want function %q", pkg, function, want)
+	}
+}
+
+func TestImportPathMain(t *testing.T) {
+	readBuildInfo()
+	defer func(previous string) { mainPackage = previous }(mainPackage)
+	mainPackage = "github.com/org/app/cmd/tool"
+	if got, want := importPath("main"), "github.com/org/app/cmd/tool"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	if got, want := importPath("github.com/org/app/sub"), "github.com/org/app/sub"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+// go test -v -test.run TestFilePathHeader ...glog
+func TestFilePathHeader(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func(previous filePath) { logging.filePath = previous }(logging.filePath)
+	pc, _, _, _ := runtime.Caller(0)
+	pkg, function := splitFunction(runtime.FuncForPC(pc).Name())
+	defer func(previous string) { mainModule = previous }(mainModulePath())
+	mainModule = pkg[:strings.LastIndex(pkg, "/")]
+	for mode, want := range map[filePath]string{
+		basePath:    " glog_caller_test.go:",
+		packagePath: " " + pkg + "/glog_caller_test.go:",
+		modulePath:  " " + pkg[len(mainModule)+1:] + "/glog_caller_test.go:",
+		funcPath:    " " + function + ":",
+	} {
+		logging.filePath = mode
+		Info("test")
+		if !contains(infoLog, want, t) {
+			t.Errorf("%s: missing %q in %q", mode.String(), want, contents(infoLog))
+		}
+	}
+}
+
+// go test -v -test.run TestFilePathRecord ...glog
+func TestFilePathRecord(t *testing.T) {
+	sink := new(captureSink)
+	l, err := New(Options{LogDir: t.TempDir(), FilePath: "func", Sinks: []Sink{sink}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.Info("test")
+	if got := sink.records[0].File; !strings.HasSuffix(got, ".TestFilePathRecord") {
+		t.Errorf("got file %q", got)
+	}
+	if _, err := New(Options{FilePath: "full"}); err == nil {
+		t.Error("expected error")
+	}
+}
+
+// go test -v -test.run TestFilePathBacktrace ...glog
+func TestFilePathBacktrace(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func(previous filePath) { logging.filePath = previous }(logging.filePath)
+	logging.filePath = packagePath
+	_, _, line, _ := runtime.Caller(0)
+	if err := logging.traceLocation.Set(fmt.Sprintf("glog_caller_test.go:%d", line+5)); err != nil {
+		t.Fatal(err)
+	}
+	defer logging.traceLocation.Set("")
+	Info("we want a stack trace here")
+	if !contains(infoLog, "goroutine ", t) {
+		t.Error("got no trace back; log is ", contents(infoLog))
+	}
+}
@@ -0,0 +1,151 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Compression of rotated log files.
+
+package glog
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Compressor compresses log files after rotation. Implementations for other
+// formats, such as zstd, can be set with SetCompressor or Options.Compressor.
+type Compressor interface {
+	// Suffix is appended to the name of a compressed file, such as ".gz".
+	Suffix() string
+	// NewWriter returns a writer that compresses to w. It is closed after the file is written.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+// GzipCompressor compresses log files with gzip. It is used by -log_compress.
+var GzipCompressor Compressor = gzipCompressor{}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Suffix() string { return ".gz" }
+
+func (gzipCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+// SetCompressor sets the Compressor for log files of the default Logger
+// after rotation; nil disables compression.
+func SetCompressor(c Compressor) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.compressor = c
+	logging.compress = c != nil
+}
+
+// fileCompressor returns the Compressor for rotated files, or nil.
+// l.mu is held.
+func (l *loggingT) fileCompressor() Compressor {
+	if l.compressor != nil {
+		return l.compressor
+	}
+	if l.compress {
+		return GzipCompressor
+	}
+	return nil
+}
+
+// compressFile compresses the closed log file name in the background.
+// Retention does not remove it, nor its compressed form, until it is done.
+// l.mu is held.
+func (l *loggingT) compressFile(name string) {
+	c := l.fileCompressor()
+	if c == nil {
+		return
+	}
+	target := name + c.Suffix()
+	l.compressingMu.Lock()
+	if l.compressing == nil {
+		l.compressing = map[string]bool{}
+	}
+	l.compressing[name], l.compressing[target] = true, true
+	l.compressingMu.Unlock()
+	l.background.Add(1)
+	go func() {
+		defer l.background.Done()
+		if err := compressFile(c, name); err != nil {
+			fmt.Fprintf(os.Stderr, "[glog error] unable to compress log file: %v\n", err)
+		}
+		l.compressingMu.Lock()
+		delete(l.compressing, name)
+		delete(l.compressing, target)
+		l.compressingMu.Unlock()
+	}()
+}
+
+// errCompressing is returned by removeUnlessCompressing for a file that is being compressed.
+var errCompressing = errors.New("log: file is being compressed")
+
+// removeUnlessCompressing removes the file path unless it is being compressed.
+func (l *loggingT) removeUnlessCompressing(path string) error {
+	l.compressingMu.Lock()
+	defer l.compressingMu.Unlock()
+	if l.compressing[path] {
+		return errCompressing
+	}
+	return os.Remove(path)
+}
+
+// compressFile writes the compressed contents of name to name plus the suffix
+// of c, keeping its permissions and modification time, then removes name.
+// If the compressed file exists, name is kept uncompressed.
+func compressFile(c Compressor, name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	target := name + c.Suffix()
+	dst, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	err = copyCompressed(c, dst, src)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(target) // ignore error
+		return err
+	}
+	os.Chtimes(target, info.ModTime(), info.ModTime()) // ignore error
+	return os.Remove(name)
+}
+
+func copyCompressed(c Compressor, dst io.Writer, src io.Reader) error {
+	w, err := c.NewWriter(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, src); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
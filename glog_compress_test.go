@@ -0,0 +1,88 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// go test -v -test.run TestCompressRotated ...glog
+func TestCompressRotated(t *testing.T) {
+	dir := t.TempDir()
+	defer func(previous func() time.Time) { timeNow = previous }(timeNow)
+	now := time.Date(2006, 1, 2, 15, 4, 5, 0, time.Local)
+	timeNow = func() time.Time { return now }
+
+	logger, err := New(Options{LogDir: dir, RotateInterval: time.Hour, Compress: true, StderrThreshold: "FATAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("first file")
+	info := logger.l.file[infoLog].(*syncBuffer)
+	fname0 := info.file.Name()
+	now = now.Add(time.Hour)
+	logger.Info("second file")
+	if err := logger.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(fname0); !os.IsNotExist(err) {
+		t.Errorf("rotated file not removed: %v", err)
+	}
+	f, err := os.Open(fname0 + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "] first file\n") || strings.Contains(string(data), "second file") {
+		t.Errorf("unexpected contents: %q", data)
+	}
+	if _, err := os.Stat(info.file.Name()); err != nil {
+		t.Errorf("current file: %v", err)
+	}
+}
+
+func TestCompressKeepsExisting(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "INFO.log")
+	for _, each := range []string{name, name + ".gz"} {
+		if err := ioutil.WriteFile(each, []byte(each), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := compressFile(GzipCompressor, name); !os.IsExist(err) {
+		t.Errorf("got %v want an error for the existing compressed file", err)
+	}
+	for _, each := range []string{name, name + ".gz"} {
+		if got := readFile(t, each); got != each {
+			t.Errorf("got %q in %s want it unchanged", got, each)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ContextExtractor returns the fields, such as request and trace IDs, that a
+// context carries. It must be safe for concurrent use and may return nil.
+type ContextExtractor func(ctx context.Context) []Field
+
+// contextExtractors holds the registered extractors, maintained under extractorsMu.
+var (
+	extractorsMu      sync.RWMutex
+	contextExtractors []ContextExtractor
+)
+
+// RegisterContextExtractor adds an extractor whose fields are added to every
+// record logged by the Context functions, such as InfoContext, of all Loggers.
+// Fields are added in order of registration.
+func RegisterContextExtractor(extractor ContextExtractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+	contextExtractors = append(contextExtractors, extractor)
+}
+
+// contextFields returns the fields of all registered extractors for ctx.
+func contextFields(ctx context.Context) []Field {
+	if ctx == nil {
+		return nil
+	}
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+	var fields []Field
+	for _, extract := range contextExtractors {
+		fields = append(fields, extract(ctx)...)
+	}
+	return fields
+}
+
+func (lg *Logger) printContext(ctx context.Context, s severity, depth int, args ...interface{}) {
+	buf, r := lg.l.header(s, depth)
+	fmt.Fprint(buf, args...)
+	r.Fields = contextFields(ctx)
+	lg.emit(r, buf)
+}
+
+// InfoContext logs to the INFO log with the fields extracted from ctx.
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func InfoContext(ctx context.Context, args ...interface{}) {
+	defaultLogger.printContext(ctx, infoLog, 0, args...)
+}
+
+// WarningContext logs to the WARNING and INFO logs with the fields extracted from ctx.
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func WarningContext(ctx context.Context, args ...interface{}) {
+	defaultLogger.printContext(ctx, warningLog, 0, args...)
+}
+
+// ErrorContext logs to the ERROR, WARNING, and INFO logs with the fields extracted from ctx.
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func ErrorContext(ctx context.Context, args ...interface{}) {
+	defaultLogger.printContext(ctx, errorLog, 0, args...)
+}
+
+// FatalContext logs to the FATAL, ERROR, WARNING, and INFO logs with the fields extracted from ctx,
+// including a stack trace of all running goroutines, then calls os.Exit(255).
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func FatalContext(ctx context.Context, args ...interface{}) {
+	defaultLogger.printContext(ctx, fatalLog, 0, args...)
+}
+
+// InfoContext is equivalent to the global InfoContext function, guarded by the value of v.
+// See the documentation of V for usage.
+func (v Verbose) InfoContext(ctx context.Context, args ...interface{}) {
+	if v {
+		defaultLogger.printContext(ctx, infoLog, 0, args...)
+	}
+}
+
+// InfoContext logs to the INFO log with the fields extracted from ctx.
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func (lg *Logger) InfoContext(ctx context.Context, args ...interface{}) {
+	lg.printContext(ctx, infoLog, 0, args...)
+}
+
+// WarningContext logs to the WARNING and INFO logs with the fields extracted from ctx.
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func (lg *Logger) WarningContext(ctx context.Context, args ...interface{}) {
+	lg.printContext(ctx, warningLog, 0, args...)
+}
+
+// ErrorContext logs to the ERROR, WARNING, and INFO logs with the fields extracted from ctx.
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func (lg *Logger) ErrorContext(ctx context.Context, args ...interface{}) {
+	lg.printContext(ctx, errorLog, 0, args...)
+}
+
+// FatalContext logs to the FATAL, ERROR, WARNING, and INFO logs with the fields extracted from ctx,
+// including a stack trace of all running goroutines, then calls os.Exit(255).
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func (lg *Logger) FatalContext(ctx context.Context, args ...interface{}) {
+	lg.printContext(ctx, fatalLog, 0, args...)
+}
+
+// InfoContext is equivalent to Logger.InfoContext, guarded by the value of v.
+func (v VerboseLogger) InfoContext(ctx context.Context, args ...interface{}) {
+	if v.enabled {
+		v.lg.printContext(ctx, infoLog, 0, args...)
+	}
+}
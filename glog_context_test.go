@@ -0,0 +1,64 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+type traceKey struct{}
+
+// go test -v -test.run TestInfoContext ...glog
+func TestInfoContext(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func(previous []ContextExtractor) { contextExtractors = previous }(contextExtractors)
+	RegisterContextExtractor(func(ctx context.Context) []Field {
+		if id, ok := ctx.Value(traceKey{}).(string); ok {
+			return []Field{{"trace_id", id}}
+		}
+		return nil
+	})
+	defer func() { logstash.toLogstash = false }()
+	logstash.toLogstash = true
+	capture := new(bytes.Buffer)
+	SetLogstashWriter(capture)
+
+	ctx := context.WithValue(context.Background(), traceKey{}, "4bf92f35")
+	With("request", "r1").InfoContext(ctx, "handled")
+	V(0).InfoContext(context.Background(), "no trace")
+	Flush()
+
+	if want := "] handled request=r1 trace_id=4bf92f35\n"; !contains(infoLog, want, t) {
+		t.Errorf("missing %q in %q", want, contents(infoLog))
+	}
+	if want := "] no trace\n"; !contains(infoLog, want, t) {
+		t.Errorf("missing %q in %q", want, contents(infoLog))
+	}
+	var event struct {
+		Fields map[string]interface{} `json:"@fields"`
+	}
+	if err := json.NewDecoder(capture).Decode(&event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Fields["trace_id"] != "4bf92f35" || event.Fields["request"] != "r1" {
+		t.Errorf("unexpected @fields: %v", event.Fields)
+	}
+}
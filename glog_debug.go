@@ -19,45 +19,127 @@ package glog
 import "strconv"
 
 const (
-	DEBUG = 10 // severity levels
+	DEBUG = 10 // verbosity levels at which the DEBUG and TRACE severities are logged
 	TRACE = 100
 )
 
-// DebugEnabled returns true if the severity level is set to DEBUG or higher.
+// DEBUG and TRACE are severities with their own header letter ('D' and 'T'),
+// Stats and, with -log_debug_files, log files. Like V logs, they are enabled
+// by -v and -vmodule: Debug logs if V(DEBUG) holds at the call site.
+
+// DebugEnabled returns true if the severity level at the call site is set to DEBUG or higher.
 func DebugEnabled() bool {
-	return logging.verbosity >= DEBUG
+	return logging.v(DEBUG, 0)
 }
 
-// TraceEnabled returns true if the severity level is set to TRACE or higher.
+// TraceEnabled returns true if the severity level at the call site is set to TRACE or higher.
 func TraceEnabled() bool {
-	return logging.verbosity >= TRACE
+	return logging.v(TRACE, 0)
 }
 
-// Debug prints the message if the severity level is set to DEBUG or higher.
+// Debug logs to the DEBUG log if the severity level is set to DEBUG or higher.
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
 func Debug(args ...interface{}) {
-	if logging.verbosity >= DEBUG {
-		logging.print(infoLog, args)
+	if logging.v(DEBUG, 0) {
+		defaultLogger.print(debugLog, 0, args...)
 	}
 }
 
-// Debug prints the formatted message if the severity level is set to DEBUG or higher.
+// Debugf logs to the DEBUG log if the severity level is set to DEBUG or higher.
+// Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
 func Debugf(format string, args ...interface{}) {
-	if logging.verbosity >= DEBUG {
-		logging.printf(infoLog, format, args...)
+	if logging.v(DEBUG, 0) {
+		defaultLogger.printf(debugLog, 0, format, args...)
+	}
+}
+
+// DebugDepth acts as Debug but uses depth to determine which call frame to log.
+// DebugDepth(0, "msg") is the same as Debug("msg").
+func DebugDepth(depth int, args ...interface{}) {
+	if logging.v(DEBUG, depth) {
+		defaultLogger.print(debugLog, depth, args...)
 	}
 }
 
-// Trace prints the message if the severity level is set to TRACE or higher.
+// Trace logs to the TRACE log if the severity level is set to TRACE or higher.
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
 func Trace(args ...interface{}) {
-	if logging.verbosity >= TRACE {
-		logging.print(infoLog, args)
+	if logging.v(TRACE, 0) {
+		defaultLogger.print(traceLog, 0, args...)
 	}
 }
 
-// Tracef prints the formatted message if the severity level is set to TRACE or higher.
+// Tracef logs to the TRACE log if the severity level is set to TRACE or higher.
+// Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
 func Tracef(format string, args ...interface{}) {
-	if logging.verbosity >= TRACE {
-		logging.printf(infoLog, format, args...)
+	if logging.v(TRACE, 0) {
+		defaultLogger.printf(traceLog, 0, format, args...)
+	}
+}
+
+// TraceDepth acts as Trace but uses depth to determine which call frame to log.
+// TraceDepth(0, "msg") is the same as Trace("msg").
+func TraceDepth(depth int, args ...interface{}) {
+	if logging.v(TRACE, depth) {
+		defaultLogger.print(traceLog, depth, args...)
+	}
+}
+
+// DebugEnabled returns true if the severity level of the Logger at the call site is set to DEBUG or higher.
+func (lg *Logger) DebugEnabled() bool {
+	return lg.l.v(DEBUG, 0)
+}
+
+// TraceEnabled returns true if the severity level of the Logger at the call site is set to TRACE or higher.
+func (lg *Logger) TraceEnabled() bool {
+	return lg.l.v(TRACE, 0)
+}
+
+// Debug logs to the DEBUG log if the severity level of the Logger is set to DEBUG or higher.
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func (lg *Logger) Debug(args ...interface{}) {
+	if lg.l.v(DEBUG, 0) {
+		lg.print(debugLog, 0, args...)
+	}
+}
+
+// Debugf logs to the DEBUG log if the severity level of the Logger is set to DEBUG or higher.
+// Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
+func (lg *Logger) Debugf(format string, args ...interface{}) {
+	if lg.l.v(DEBUG, 0) {
+		lg.printf(debugLog, 0, format, args...)
+	}
+}
+
+// DebugDepth acts as Debug but uses depth to determine which call frame to log.
+// DebugDepth(0, "msg") is the same as Debug("msg").
+func (lg *Logger) DebugDepth(depth int, args ...interface{}) {
+	if lg.l.v(DEBUG, depth) {
+		lg.print(debugLog, depth, args...)
+	}
+}
+
+// Trace logs to the TRACE log if the severity level of the Logger is set to TRACE or higher.
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func (lg *Logger) Trace(args ...interface{}) {
+	if lg.l.v(TRACE, 0) {
+		lg.print(traceLog, 0, args...)
+	}
+}
+
+// Tracef logs to the TRACE log if the severity level of the Logger is set to TRACE or higher.
+// Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
+func (lg *Logger) Tracef(format string, args ...interface{}) {
+	if lg.l.v(TRACE, 0) {
+		lg.printf(traceLog, 0, format, args...)
+	}
+}
+
+// TraceDepth acts as Trace but uses depth to determine which call frame to log.
+// TraceDepth(0, "msg") is the same as Trace("msg").
+func (lg *Logger) TraceDepth(depth int, args ...interface{}) {
+	if lg.l.v(TRACE, depth) {
+		lg.print(traceLog, depth, args...)
 	}
 }
 
@@ -17,6 +17,8 @@
 package glog
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -52,3 +54,75 @@ func logit() {
 		Tracef("formatted info is %s", "trace")
 	}
 }
+
+// go test -v -test.run TestDebugSeverity ...glog
+func TestDebugSeverity(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer SetVerbosity(0)
+	SetVerbosity(DEBUG)
+	lines := Stats.Debug.Lines()
+	Debug("debug line")
+	Trace("not traced")
+	if !strings.HasPrefix(contents(infoLog), "D") || !contains(infoLog, "] debug line\n", t) {
+		t.Errorf("Debug has wrong content: %q", contents(infoLog))
+	}
+	if contains(infoLog, "not traced", t) {
+		t.Error("Trace logged at DEBUG level")
+	}
+	if got := Stats.Debug.Lines(); got != lines+1 {
+		t.Errorf("got %d debug lines, want %d", got, lines+1)
+	}
+}
+
+// go test -v -test.run TestDebugFiles ...glog
+func TestDebugFiles(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func() { logging.debugFiles = false }()
+	logging.debugFiles = true
+	defer SetVerbosity(0)
+	SetVerbosity(TRACE)
+	Tracef("%s line", "trace")
+	Info("info line")
+	if !strings.HasPrefix(contents(traceLog), "T") || !contains(traceLog, "] trace line\n", t) {
+		t.Errorf("Trace has wrong content: %q", contents(traceLog))
+	}
+	if contains(debugLog, "trace line", t) || contains(infoLog, "trace line", t) {
+		t.Error("Trace written to DEBUG or INFO file")
+	}
+	if !contains(debugLog, "info line", t) || !contains(traceLog, "info line", t) {
+		t.Error("Info not written to DEBUG and TRACE files")
+	}
+}
+
+// go test -v -test.run TestDebugVmodule ...glog
+func TestDebugVmodule(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	logging.vmodule.Set("glog_debug_test=10")
+	defer logging.vmodule.Set("")
+	if !DebugEnabled() || TraceEnabled() {
+		t.Error("vmodule does not enable DEBUG only")
+	}
+	Debug("enabled by vmodule")
+	if !contains(infoLog, "enabled by vmodule", t) {
+		t.Error("Debug not enabled by vmodule")
+	}
+}
+
+// go test -v -test.run TestStderrThresholdFlag ...glog
+func TestStderrThresholdFlag(t *testing.T) {
+	defer logging.stderrThreshold.set(logging.stderrThreshold.get())
+	for value, want := range map[string]string{"ERROR": "2", "1": "1", "debug": "-1", "-2": "-2"} {
+		if err := logging.stderrThreshold.Set(value); err != nil {
+			t.Fatal(err)
+		}
+		if got := logging.stderrThreshold.String(); got != want {
+			t.Errorf("Set(%q): got %s want %s", value, got, want)
+		}
+		if got := fmt.Sprint(logging.stderrThreshold.Get()); got != want {
+			t.Errorf("Set(%q): Get got %s want %s", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Durability of writes to log files.
+
+package glog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// durability is what happens to the log files after writing a record.
+type durability int8
+
+const (
+	buffered durability = iota // written by the flushDaemon or when the buffer is full.
+	flushed                    // flushed to the operating system after each record.
+	synced                     // flushed and synced to disk after each record.
+)
+
+var durabilityName = []string{
+	buffered: "buffered",
+	flushed:  "flush",
+	synced:   "sync",
+}
+
+// durabilityMap holds the durability of records per severity.
+// It is the type of the -log_durability flag.
+type durabilityMap struct {
+	text     string
+	severity [numSeverity]durability
+}
+
+func (m *durabilityMap) String() string {
+	return m.text
+}
+
+// Get is part of the flag.Value interface.
+func (m *durabilityMap) Get() interface{} {
+	return m.text
+}
+
+// Set is part of the flag.Value interface.
+func (m *durabilityMap) Set(value string) error {
+	parsed, err := parseDurability(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	*m = parsed
+	return nil
+}
+
+// parseDurability parses a comma-separated list of SEVERITY=MODE settings, such as
+// "WARNING=flush,ERROR=sync". A setting applies to the severity and all higher
+// severities; later settings override earlier ones.
+func parseDurability(value string) (durabilityMap, error) {
+	m := durabilityMap{text: value}
+	if value == "" {
+		return m, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		sevAndMode := strings.Split(entry, "=")
+		if len(sevAndMode) != 2 {
+			return durabilityMap{}, fmt.Errorf("log: invalid durability %q: want SEVERITY=buffered, flush or sync", entry)
+		}
+		sev, ok := severityByName(sevAndMode[0])
+		if !ok {
+			return durabilityMap{}, fmt.Errorf("log: unknown severity %q in durability", sevAndMode[0])
+		}
+		mode := durability(-1)
+		for d, name := range durabilityName {
+			if sevAndMode[1] == name {
+				mode = durability(d)
+			}
+		}
+		if mode < 0 {
+			return durabilityMap{}, fmt.Errorf("log: unknown durability %q: want buffered, flush or sync", sevAndMode[1])
+		}
+		for s := sev; s < numSeverity; s++ {
+			m.severity[s] = mode
+		}
+	}
+	return m, nil
+}
+
+// interval is a duration that is handled atomically.
+// It is the type of the -log_flush_interval flag.
+type interval struct {
+	d       int64         // The time.Duration.
+	changed chan struct{} // If not nil, receives a value when the duration is set.
+}
+
+func (i *interval) get() time.Duration {
+	return time.Duration(atomic.LoadInt64(&i.d))
+}
+
+func (i *interval) set(d time.Duration) {
+	atomic.StoreInt64(&i.d, int64(d))
+	select {
+	case i.changed <- struct{}{}:
+	default: // a change is pending already
+	}
+}
+
+func (i *interval) String() string {
+	return i.get().String()
+}
+
+// Get is part of the flag.Value interface.
+func (i *interval) Get() interface{} {
+	return i.get()
+}
+
+// Set is part of the flag.Value interface.
+func (i *interval) Set(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	if d <= 0 {
+		return fmt.Errorf("log: flush interval must be positive, got %v", d)
+	}
+	i.set(d)
+	return nil
+}
+
+// byteCount is a positive number of bytes. It is the type of the -log_buffer_size flag.
+type byteCount int
+
+func (c *byteCount) String() string {
+	return strconv.Itoa(int(*c))
+}
+
+// Get is part of the flag.Value interface.
+func (c *byteCount) Get() interface{} {
+	return int(*c)
+}
+
+// Set is part of the flag.Value interface.
+func (c *byteCount) Set(value string) error {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	if n <= 0 {
+		return fmt.Errorf("log: buffer size must be positive, got %d", n)
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	*c = byteCount(n)
+	return nil
+}
@@ -0,0 +1,110 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDurability(t *testing.T) {
+	m, err := parseDurability("WARNING=flush,ERROR=sync,FATAL=buffered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [numSeverity]durability{buffered, buffered, buffered, flushed, synced, buffered}
+	if m.severity != want {
+		t.Errorf("got %v want %v", m.severity, want)
+	}
+	for _, value := range []string{"sync", "LOUD=sync", "ERROR=always"} {
+		if _, err := parseDurability(value); err == nil {
+			t.Errorf("%q: expected error", value)
+		}
+	}
+}
+
+// go test -v -test.run TestDurability ...glog
+func TestDurability(t *testing.T) {
+	logger, err := New(Options{LogDir: t.TempDir(), Durability: "ERROR=sync", NoFileHeader: true, StderrThreshold: "FATAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("buffered")
+	name := logger.l.file[infoLog].(*syncBuffer).file.Name()
+	if got := readFile(t, name); got != "" {
+		t.Errorf("got %q want nothing written before a flush", got)
+	}
+	logger.Error("synced")
+	if got := readFile(t, name); !strings.Contains(got, "] buffered\n") || !strings.Contains(got, "] synced\n") {
+		t.Errorf("got %q want both records written", got)
+	}
+}
+
+// go test -v -test.run TestFlushInterval ...glog
+func TestFlushInterval(t *testing.T) {
+	logger, err := New(Options{LogDir: t.TempDir(), FlushInterval: 10 * time.Millisecond, StderrThreshold: "FATAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("flushed")
+	logger.l.mu.Lock()
+	name := logger.l.file[infoLog].(*syncBuffer).file.Name()
+	logger.l.mu.Unlock()
+	for i := 0; i < 500; i++ {
+		if strings.Contains(readFile(t, name), "] flushed\n") {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("record not flushed by the flush daemon")
+}
+
+// go test -v -test.run TestFlushIntervalChange ...glog
+func TestFlushIntervalChange(t *testing.T) {
+	logger, err := New(Options{LogDir: t.TempDir(), FlushInterval: time.Hour, StderrThreshold: "FATAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("flushed")
+	logger.l.mu.Lock()
+	name := logger.l.file[infoLog].(*syncBuffer).file.Name()
+	logger.l.mu.Unlock()
+	logger.l.flushInterval.set(10 * time.Millisecond)
+	for i := 0; i < 500; i++ {
+		if strings.Contains(readFile(t, name), "] flushed\n") {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("record not flushed after the flush interval changed")
+}
+
+func TestBufferSizeFlag(t *testing.T) {
+	var c byteCount
+	for _, value := range []string{"0", "-1", "big"} {
+		if err := c.Set(value); err == nil {
+			t.Errorf("%q: expected error", value)
+		}
+	}
+	if err := c.Set("4096"); err != nil || c != 4096 {
+		t.Errorf("got %d, %v want 4096", c, err)
+	}
+}
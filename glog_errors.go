@@ -0,0 +1,162 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Handling of errors creating or writing log files.
+
+package glog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// ErrorPolicy is what happens when a log file cannot be created or written.
+// It is the type of the -log_error_policy flag.
+type ErrorPolicy int32
+
+const (
+	// ExitOnError flushes the logs and exits the program with status 2.
+	ExitOnError ErrorPolicy = iota
+	// StderrOnError writes the records to standard error instead.
+	StderrOnError
+	// DropOnError discards the records, counted by Stats.Dropped.
+	DropOnError
+	// RetryOnError writes the record again to a new file, then creates a new file a few times
+	// with backoff, writing the records in between to standard error.
+	RetryOnError
+)
+
+var errorPolicyName = []string{
+	ExitOnError:   "exit",
+	StderrOnError: "stderr",
+	DropOnError:   "drop",
+	RetryOnError:  "retry",
+}
+
+// String is part of the flag.Value interface.
+func (p *ErrorPolicy) String() string {
+	if *p < 0 || int(*p) >= len(errorPolicyName) {
+		return fmt.Sprintf("ErrorPolicy(%d)", int32(*p))
+	}
+	return errorPolicyName[*p]
+}
+
+// Get is part of the flag.Value interface.
+func (p *ErrorPolicy) Get() interface{} {
+	return *p
+}
+
+// Set is part of the flag.Value interface.
+func (p *ErrorPolicy) Set(value string) error {
+	for i, name := range errorPolicyName {
+		if value == name {
+			atomic.StoreInt32((*int32)(p), int32(i))
+			return nil
+		}
+	}
+	return fmt.Errorf("log: unknown error policy %q: want exit, stderr, drop or retry", value)
+}
+
+func (p *ErrorPolicy) get() ErrorPolicy {
+	return ErrorPolicy(atomic.LoadInt32((*int32)(p)))
+}
+
+// SetOnError sets a function that is called with each error creating or
+// writing a log file of the default Logger, instead of reporting it on
+// standard error. It is not called for ExitOnError. The function must not log.
+func SetOnError(f func(err error)) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.onError = f
+}
+
+const (
+	// recoverInterval is the time after an error before a new log file is created.
+	recoverInterval = 10 * time.Second
+	// retryBackoff is the first wait of RetryOnError; it doubles for each of maxRetries attempts.
+	retryBackoff = 10 * time.Millisecond
+	maxRetries   = 3
+)
+
+// errCreateLater is returned by createFiles until recoverInterval has passed after an error.
+var errCreateLater = errors.New("log: waiting to create log files after an error")
+
+// retryDelay returns the wait before creating a file again after the given number
+// of failed attempts, and the number of attempts after this one. With RetryOnError the
+// waits grow from retryBackoff; afterwards, and with other policies, it is recoverInterval.
+func (l *loggingT) retryDelay(attempts int) (time.Duration, int) {
+	if l.errorPolicy.get() == RetryOnError && attempts < maxRetries {
+		return retryBackoff << uint(attempts), attempts + 1
+	}
+	return recoverInterval, 0
+}
+
+// fileError handles err creating or writing the log files for the record data,
+// once per record. l.mu is held.
+func (l *loggingT) fileError(err error, data []byte) {
+	if err == errCreateLater {
+		l.fallback(data)
+		return
+	}
+	if l.errorPolicy.get() == ExitOnError {
+		os.Stderr.Write(data) // Make sure the message appears somewhere.
+		l.exit(err)
+		return
+	}
+	l.reportError(err)
+	l.fallback(data)
+}
+
+// reportError calls the OnError function or reports err on standard error.
+// l.mu is held.
+func (l *loggingT) reportError(err error) {
+	if l.onError != nil {
+		l.onError(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "[glog error] %v\n", err)
+}
+
+// fallback writes the data that could not be written to a log file to
+// standard error, or counts it as dropped.
+func (l *loggingT) fallback(data []byte) {
+	if l.errorPolicy.get() == DropOnError {
+		atomic.AddInt64(&Stats.Dropped.lines, 1)
+		atomic.AddInt64(&Stats.Dropped.bytes, int64(len(data)))
+		return
+	}
+	os.Stderr.Write(data)
+}
+
+// failed records err writing or creating a new file at now. Unless the policy
+// is ExitOnError, the file is not used until sb.retryAt. It returns
+// errCreateLater while RetryOnError has attempts left, so that err is not reported.
+// l.mu is held.
+func (sb *syncBuffer) failed(err error, now time.Time) error {
+	if sb.logger.errorPolicy.get() == ExitOnError {
+		return err
+	}
+	var delay time.Duration
+	delay, sb.attempts = sb.logger.retryDelay(sb.attempts)
+	sb.err, sb.retryAt = err, now.Add(delay)
+	if sb.attempts > 0 {
+		return errCreateLater
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestErrorPolicyFlag(t *testing.T) {
+	var p ErrorPolicy
+	if err := p.Set("drop"); err != nil || p != DropOnError || p.String() != "drop" {
+		t.Errorf("got %v, %v want drop", p, err)
+	}
+	if err := p.Set("ignore"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+// go test -v -test.run TestDropOnError ...glog
+func TestDropOnError(t *testing.T) {
+	defer func(previous func() time.Time) { timeNow = previous }(timeNow)
+	now := time.Date(2006, 1, 2, 15, 4, 5, 0, time.Local)
+	timeNow = func() time.Time { return now }
+	var errs []error
+	logger, err := New(Options{
+		LogDir:          t.TempDir(),
+		BufferSize:      16, // each record is written to the file
+		ErrorPolicy:     DropOnError,
+		OnError:         func(err error) { errs = append(errs, err) },
+		StderrThreshold: "FATAL",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("written")
+	info := logger.l.file[infoLog].(*syncBuffer)
+	fname0 := info.file.Name()
+	info.file.Close() // simulate a failing disk
+
+	dropped := Stats.Dropped.Lines()
+	logger.Info("dropped")
+	logger.Info("dropped too")
+	if len(errs) != 1 {
+		t.Errorf("got %d errors want 1: %v", len(errs), errs)
+	}
+	if got := Stats.Dropped.Lines() - dropped; got != 2 {
+		t.Errorf("got %d dropped lines want 2", got)
+	}
+
+	now = now.Add(recoverInterval)
+	logger.Info("recovered")
+	if info.err != nil || info.file.Name() == fname0 {
+		t.Fatalf("no new file after recoverInterval: %v", info.err)
+	}
+	logger.Flush()
+	if got := readFile(t, info.file.Name()); !strings.Contains(got, "] recovered\n") {
+		t.Errorf("missing record in new file: %q", got)
+	}
+	if got := readFile(t, fname0); !strings.Contains(got, "] written\n") || strings.Contains(got, "dropped") {
+		t.Errorf("unexpected first file: %q", got)
+	}
+}
+
+// go test -v -test.run TestRetryOnError ...glog
+func TestRetryOnError(t *testing.T) {
+	var errs []error
+	logger, err := New(Options{
+		LogDir:          t.TempDir(),
+		FileTemplate:    "{tag}.{seq}.log",
+		BufferSize:      16,
+		ErrorPolicy:     RetryOnError,
+		OnError:         func(err error) { errs = append(errs, err) },
+		StderrThreshold: "FATAL",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("first")
+	info := logger.l.file[infoLog].(*syncBuffer)
+	info.file.Close()
+	logger.Info("retried")
+	if len(errs) != 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	logger.Flush()
+	if got := readFile(t, info.file.Name()); !strings.HasSuffix(info.file.Name(), "INFO.1.log") || !strings.Contains(got, "] retried\n") {
+		t.Errorf("missing record in %s: %q", info.file.Name(), got)
+	}
+}
+
+// go test -v -test.run TestDropOnErrorOncePerRecord ...glog
+func TestDropOnErrorOncePerRecord(t *testing.T) {
+	var errs []error
+	logger, err := New(Options{
+		LogDir:          t.TempDir(),
+		BufferSize:      16,
+		ErrorPolicy:     DropOnError,
+		OnError:         func(err error) { errs = append(errs, err) },
+		StderrThreshold: "FATAL",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Error("written")
+	for s := infoLog; s <= errorLog; s++ {
+		logger.l.file[s].(*syncBuffer).file.Close()
+	}
+	dropped := Stats.Dropped.Lines()
+	logger.Error("dropped from the ERROR, WARNING and INFO files")
+	if len(errs) != 1 {
+		t.Errorf("got %d errors want 1: %v", len(errs), errs)
+	}
+	if got := Stats.Dropped.Lines() - dropped; got != 1 {
+		t.Errorf("got %d dropped lines want 1", got)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	l := &loggingT{}
+	l.errorPolicy.Set("retry")
+	var delays []time.Duration
+	attempts := 0
+	for i := 0; i < 5; i++ {
+		var d time.Duration
+		d, attempts = l.retryDelay(attempts)
+		delays = append(delays, d)
+	}
+	want := []time.Duration{retryBackoff, 2 * retryBackoff, 4 * retryBackoff, recoverInterval, retryBackoff}
+	for i := range want {
+		if delays[i] != want[i] {
+			t.Errorf("got %v want %v", delays, want)
+			break
+		}
+	}
+	l.errorPolicy.Set("stderr")
+	if d, n := l.retryDelay(0); d != recoverInterval || n != 0 {
+		t.Errorf("got %v %d for stderr", d, n)
+	}
+}
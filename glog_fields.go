@@ -0,0 +1,202 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Field is a typed key/value pair that is carried by a Record.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// badKey is used as key for values in a key/value list that have no string key.
+const badKey = "!BADKEY"
+
+// kvFields returns the Fields for an alternating list of keys and values.
+// A Field in the list is taken as is. A value without a string key is
+// reported with key !BADKEY and a key without value gets a nil value.
+func kvFields(kv []interface{}) []Field {
+	if len(kv) == 0 {
+		return nil
+	}
+	fields := make([]Field, 0, (len(kv)+1)/2)
+	for i := 0; i < len(kv); i++ {
+		switch k := kv[i].(type) {
+		case Field:
+			fields = append(fields, k)
+		case string:
+			var v interface{}
+			if i+1 < len(kv) {
+				i++
+				v = kv[i]
+			}
+			fields = append(fields, Field{Key: k, Value: v})
+		default:
+			fields = append(fields, Field{Key: badKey, Value: k})
+		}
+	}
+	return fields
+}
+
+// writeFields appends the fields in k=v notation, each preceded by a space.
+func writeFields(buf *bytes.Buffer, fields []Field) {
+	for _, f := range fields {
+		buf.WriteByte(' ')
+		writeFieldKey(buf, f.Key)
+		buf.WriteByte('=')
+		writeFieldValue(buf, fieldString(f.Value))
+	}
+}
+
+// writeFieldKey writes a key of k=v notation, replacing the characters that
+// would need quoting by '_'.
+func writeFieldKey(buf *bytes.Buffer, key string) {
+	if key == "" {
+		buf.WriteByte('_')
+		return
+	}
+	for _, r := range key {
+		if quotedRune(r) {
+			r = '_'
+		}
+		buf.WriteRune(r)
+	}
+}
+
+// writeFieldValue writes a value of k=v notation, quoted and escaped if it is
+// empty or contains spaces, quotes, '=', non-printable characters or invalid UTF-8.
+func writeFieldValue(buf *bytes.Buffer, value string) {
+	if value != "" && strings.IndexFunc(value, quotedRune) < 0 {
+		buf.WriteString(value)
+		return
+	}
+	buf.WriteString(strconv.Quote(value))
+}
+
+// fieldKey returns key, or "fields." followed by key if it is one of the
+// reserved keys of an encoding, so that a field cannot overwrite the level,
+// the message or other keys written for each record.
+func fieldKey(key string, reserved []string) string {
+	for _, each := range reserved {
+		if key == each {
+			return "fields." + key
+		}
+	}
+	return key
+}
+
+// fieldString returns the text representation of a field value. It uses fmt
+// for errors and Stringers too, which prints <nil> for a nil pointer whose
+// method panics.
+func fieldString(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func quotedRune(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || !unicode.IsPrint(r)
+}
+
+func (lg *Logger) printw(s severity, depth int, msg string, kv []interface{}) {
+	buf, r := lg.l.header(s, depth)
+	buf.WriteString(msg)
+	r.Fields = kvFields(kv)
+	lg.emit(r, buf)
+}
+
+// Infow logs a message with key/value pairs to the INFO log.
+// The pairs are appended to the log line as k=v and passed to the sinks as Fields.
+func Infow(msg string, kv ...interface{}) {
+	defaultLogger.printw(infoLog, 0, msg, kv)
+}
+
+// Warningw logs a message with key/value pairs to the WARNING and INFO logs.
+// The pairs are appended to the log line as k=v and passed to the sinks as Fields.
+func Warningw(msg string, kv ...interface{}) {
+	defaultLogger.printw(warningLog, 0, msg, kv)
+}
+
+// Errorw logs a message with key/value pairs to the ERROR, WARNING, and INFO logs.
+// The pairs are appended to the log line as k=v and passed to the sinks as Fields.
+func Errorw(msg string, kv ...interface{}) {
+	defaultLogger.printw(errorLog, 0, msg, kv)
+}
+
+// With returns a Logger that shares the setup of the default Logger and adds
+// the key/value pairs to every record it logs.
+func With(kv ...interface{}) *Logger {
+	return defaultLogger.With(kv...)
+}
+
+// Infow is equivalent to the global Infow function, guarded by the value of v.
+// See the documentation of V for usage.
+func (v Verbose) Infow(msg string, kv ...interface{}) {
+	if v {
+		defaultLogger.printw(infoLog, 0, msg, kv)
+	}
+}
+
+// With returns a Logger that shares the setup of lg and adds the key/value pairs
+// to every record it logs, after the pairs bound to lg itself.
+// The pairs are formatted once, so it is cheap to create a Logger per request.
+func (lg *Logger) With(kv ...interface{}) *Logger {
+	fields := kvFields(kv)
+	n := len(lg.fields)
+	var buf bytes.Buffer
+	buf.Write(lg.text)
+	writeFields(&buf, fields)
+	return &Logger{
+		l:      lg.l,
+		fields: append(lg.fields[:n:n], fields...),
+		text:   buf.Bytes(),
+	}
+}
+
+// Infow logs a message with key/value pairs to the INFO log.
+// The pairs are appended to the log line as k=v and passed to the sinks as Fields.
+func (lg *Logger) Infow(msg string, kv ...interface{}) {
+	lg.printw(infoLog, 0, msg, kv)
+}
+
+// Warningw logs a message with key/value pairs to the WARNING and INFO logs.
+// The pairs are appended to the log line as k=v and passed to the sinks as Fields.
+func (lg *Logger) Warningw(msg string, kv ...interface{}) {
+	lg.printw(warningLog, 0, msg, kv)
+}
+
+// Errorw logs a message with key/value pairs to the ERROR, WARNING, and INFO logs.
+// The pairs are appended to the log line as k=v and passed to the sinks as Fields.
+func (lg *Logger) Errorw(msg string, kv ...interface{}) {
+	lg.printw(errorLog, 0, msg, kv)
+}
+
+// Infow is equivalent to Logger.Infow, guarded by the value of v.
+func (v VerboseLogger) Infow(msg string, kv ...interface{}) {
+	if v.enabled {
+		v.lg.printw(infoLog, 0, msg, kv)
+	}
+}
@@ -0,0 +1,113 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// go test -v -test.run TestInfow ...glog
+func TestInfow(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	Infow("request done", "id", 42, "path", "/a b", "err", errors.New("boom"), 3.5)
+	want := `] request done id=42 path="/a b" err=boom !BADKEY=3.5` + "\n"
+	if !strings.HasSuffix(contents(infoLog), want) {
+		t.Errorf("got %q want suffix %q", contents(infoLog), want)
+	}
+}
+
+func TestWriteFields(t *testing.T) {
+	buf := new(bytes.Buffer)
+	writeFields(buf, []Field{{"a b", "x"}, {"", 1}, {"bell", "\a"}, {"bad", "\xff"}, {"nbsp", "a\u00a0b"}, {"utf8", "héllo"}})
+	want := ` a_b=x _=1 bell="\a" bad="\xff" nbsp="a\u00a0b" utf8=héllo`
+	if got := buf.String(); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+// go test -v -test.run TestKvFields ...glog
+func TestKvFields(t *testing.T) {
+	fields := kvFields([]interface{}{"a", 1, Field{"b", true}, "c"})
+	if len(fields) != 3 {
+		t.Fatalf("got %d fields: %v", len(fields), fields)
+	}
+	if fields[0] != (Field{"a", 1}) || fields[1] != (Field{"b", true}) || fields[2] != (Field{"c", nil}) {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+}
+
+// go test -v -test.run TestErrorwLogstash ...glog
+func TestErrorwLogstash(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func() { logstash.toLogstash = false }()
+	logstash.toLogstash = true
+	capture := new(bytes.Buffer)
+	SetLogstashWriter(capture)
+	Errorw("failed", "tenant", "acme", "attempt", 3, "ok", false)
+	Flush()
+	var event struct {
+		Fields  map[string]interface{} `json:"@fields"`
+		Message string                 `json:"@message"`
+	}
+	if err := json.Unmarshal(capture.Bytes(), &event); err != nil {
+		t.Fatalf("invalid json %q: %v", capture.String(), err)
+	}
+	if event.Message != "failed" {
+		t.Errorf("got message %q", event.Message)
+	}
+	if event.Fields["level"] != "ERROR" || event.Fields["tenant"] != "acme" || event.Fields["attempt"] != 3.0 || event.Fields["ok"] != false {
+		t.Errorf("unexpected @fields: %v", event.Fields)
+	}
+}
+
+// go test -v -test.run TestWith ...glog
+func TestWith(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	sink := new(captureSink)
+	AddSink(sink)
+	defer RemoveSink(sink)
+	child := With("request", "r1").With("tenant", "acme corp")
+	child.Infow("handled", "status", 200)
+	child.Warning("plain")
+	Info("no fields")
+	for _, want := range []string{
+		`] handled request=r1 tenant="acme corp" status=200` + "\n",
+		`] plain request=r1 tenant="acme corp"` + "\n",
+		"] no fields\n",
+	} {
+		if !contains(infoLog, want, t) {
+			t.Errorf("missing %q in %q", want, contents(infoLog))
+		}
+	}
+	if len(sink.records) != 3 {
+		t.Fatalf("got %d records", len(sink.records))
+	}
+	fields := sink.records[0].Fields
+	if len(fields) != 3 || fields[0].Key != "request" || fields[1].Key != "tenant" || fields[2] != (Field{"status", 200}) {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+	if sink.records[0].Message != "handled" || len(sink.records[2].Fields) != 0 {
+		t.Errorf("unexpected records: %+v", sink.records)
+	}
+}
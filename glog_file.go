@@ -20,31 +20,75 @@ package glog
 
 import (
 	"errors"
-	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
 // MaxSize is the maximum size of a log file in bytes.
 var MaxSize uint64 = 1024 * 1024 * 1800
 
-// logDirs lists the candidate directories for new log files.
-var logDirs []string
+// fileMode is a permission in octal, such as 0640. It is the type of the
+// -log_file_mode and -log_dir_mode flags.
+type fileMode os.FileMode
 
-// If non-empty, overrides the choice of directory in which to write logs.
-// See createLogDirs for the full list of possible destinations.
-var logDir = flag.String("log_dir", "", "If non-empty, write log files in this directory")
+func (m *fileMode) String() string {
+	return fmt.Sprintf("%#o", uint32(*m))
+}
+
+// Get is part of the flag.Value interface.
+func (m *fileMode) Get() interface{} {
+	return os.FileMode(*m)
+}
 
-func createLogDirs() {
-	if *logDir != "" {
-		logDirs = append(logDirs, *logDir)
+// Set is part of the flag.Value interface.
+func (m *fileMode) Set(value string) error {
+	v, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || v > 0777 {
+		return fmt.Errorf("log: invalid permission %q: want an octal number such as 0640", value)
 	}
-	logDirs = append(logDirs, os.TempDir())
+	*m = fileMode(v)
+	return nil
+}
+
+// createLogDirs lists the candidate directories for new log files:
+// the -log_dir flag (or Options.LogDir), if set, followed by os.TempDir()
+// unless -log_dir_fallback is false. The log directory is created if needed
+// and must be writable.
+func (l *loggingT) createLogDirs() error {
+	if l.logDir != "" {
+		err := os.MkdirAll(l.logDir, os.FileMode(l.dirMode))
+		if err == nil {
+			err = checkWritable(l.logDir)
+		}
+		if err == nil {
+			l.logDirs = append(l.logDirs, l.logDir)
+		} else if !l.dirFallback {
+			return fmt.Errorf("log: cannot use log directory %q: %v", l.logDir, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "[glog error] cannot use log directory %q, using %q instead: %v\n", l.logDir, os.TempDir(), err)
+		}
+		if !l.dirFallback {
+			return nil
+		}
+	}
+	l.logDirs = append(l.logDirs, os.TempDir())
+	return nil
+}
+
+// checkWritable returns an error if no file can be created in dir.
+func checkWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, "."+program+".")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
 }
 
 var (
@@ -78,40 +122,49 @@ func shortHostname(hostname string) string {
 	return hostname
 }
 
-// logName returns a new log file name containing tag, with start time t, and
-// the name for the symlink for tag.
-func logName(tag string, t time.Time) (name, link string) {
-	name = fmt.Sprintf("%s.%s.%s.log.%s.%04d%02d%02d-%02d%02d%02d.%d",
-		program,
-		host,
-		userName,
-		tag,
-		t.Year(),
-		t.Month(),
-		t.Day(),
-		t.Hour(),
-		t.Minute(),
-		t.Second(),
-		pid)
-	return name, program + "." + tag
+// logLink returns the name for the symlink for tag.
+func logLink(tag string) string {
+	return program + "." + tag
 }
 
-var onceLogDirs sync.Once
+// openFlag returns the flag to open a log file with. New names are created
+// exclusively so that no Logger writes to the file of another.
+func openFlag(appends bool) int {
+	if appends {
+		return os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	return os.O_WRONLY | os.O_CREATE | os.O_TRUNC | os.O_EXCL
+}
 
 // create creates a new log file and returns the file and its filename, which
-// contains tag ("INFO", "FATAL", etc.) and t.  If the file is created
-// successfully, create also attempts to update the symlink for that tag, ignoring
-// errors.
-func create(tag string, t time.Time) (f *os.File, filename string, err error) {
-	onceLogDirs.Do(createLogDirs)
-	if len(logDirs) == 0 {
+// is made from the file template with tag ("INFO", "FATAL", etc.) and t. Only
+// templates without {seq} and without a time with seconds append to an
+// existing file other than rotated; other names are unique, also between Loggers.
+// If the file is created successfully, create also attempts to update the symlink
+// for that tag, ignoring errors.
+func (l *loggingT) create(tag string, t time.Time, rotated string) (f *os.File, filename string, err error) {
+	l.onceLogDirs.Do(func() { l.logDirsErr = l.createLogDirs() })
+	if l.logDirsErr != nil {
+		return nil, "", l.logDirsErr
+	}
+	if len(l.logDirs) == 0 {
 		return nil, "", errors.New("log: no log dirs")
 	}
-	name, link := logName(tag, t)
+	template, link := l.fileTemplate.orDefault(), logLink(tag)
+	var suffix string
+	if c := l.fileCompressor(); c != nil {
+		suffix = c.Suffix()
+	}
 	var lastErr error
-	for _, dir := range logDirs {
+	for _, dir := range l.logDirs {
+		name, appends := template.name(dir, tag, t, suffix, rotated)
 		fname := filepath.Join(dir, name)
-		f, err := os.Create(fname)
+		f, err := os.OpenFile(fname, openFlag(appends), os.FileMode(l.fileMode))
+		for attempt := 0; os.IsExist(err) && attempt < 10; attempt++ { // created by another Logger
+			name, appends = template.name(dir, tag, t, suffix, rotated)
+			fname = filepath.Join(dir, name)
+			f, err = os.OpenFile(fname, openFlag(appends), os.FileMode(l.fileMode))
+		}
 		if err == nil {
 			symlink := filepath.Join(dir, link)
 			os.Remove(symlink)        // ignore err
@@ -0,0 +1,95 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// go test -v -test.run TestLogDirModes ...glog
+func TestLogDirModes(t *testing.T) {
+	dir := t.TempDir()
+	logDir := filepath.Join(dir, "a", "b")
+	logger, err := New(Options{LogDir: logDir, FileMode: 0640, DirMode: 0750, LogDirFailFast: true, StderrThreshold: "FATAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("x")
+	info, err := os.Stat(logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); !info.IsDir() || perm&^0750 != 0 {
+		t.Errorf("got log dir mode %v want at most 0750", info.Mode())
+	}
+	name := logger.l.file[infoLog].(*syncBuffer).file.Name()
+	if filepath.Dir(name) != logDir {
+		t.Errorf("got file %q want in %q", name, logDir)
+	}
+	if info, err = os.Stat(name); err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm&^0640 != 0 {
+		t.Errorf("got file mode %v want at most 0640", perm)
+	}
+}
+
+// go test -v -test.run TestLogDirFallback ...glog
+func TestLogDirFallback(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	// a directory cannot be created below a regular file
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	logDir := filepath.Join(file, "logs")
+
+	if _, err := New(Options{LogDir: logDir, LogDirFailFast: true}); err == nil || !strings.Contains(err.Error(), "cannot use log directory") {
+		t.Errorf("got %v want an error for the log directory", err)
+	}
+
+	logger, err := New(Options{LogDir: logDir, StderrThreshold: "FATAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("x")
+	if got := filepath.Dir(logger.l.file[infoLog].(*syncBuffer).file.Name()); got != dir {
+		t.Errorf("got file in %q want in %q", got, dir)
+	}
+}
+
+// go test -v -test.run TestLogDirNotWritable ...glog
+func TestLogDirNotWritable(t *testing.T) {
+	logDir := t.TempDir()
+	if err := os.Chmod(logDir, 0555); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(logDir, 0755)
+	if checkWritable(logDir) == nil {
+		t.Skip("permissions are not enforced, for instance for root")
+	}
+	if _, err := New(Options{LogDir: logDir, LogDirFailFast: true}); err == nil || !strings.Contains(err.Error(), "cannot use log directory") {
+		t.Errorf("got %v want an error for the log directory", err)
+	}
+}
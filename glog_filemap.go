@@ -0,0 +1,124 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Mapping of severities to log files.
+
+package glog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// fileMap maps each severity to the log files it is written to. The zero
+// value writes each severity to its own file and those of all lower
+// severities, down to INFO or, with -log_debug_files, TRACE.
+// It is the type of the -log_files flag.
+type fileMap struct {
+	text      string
+	single    bool                    // all severities go to the INFO file.
+	errorFile bool                    // with single, ERROR and FATAL also go to the ERROR file.
+	override  [numSeverity][]severity // if non-nil, the files of a severity.
+}
+
+func (m *fileMap) String() string {
+	return m.text
+}
+
+// Get is part of the flag.Value interface.
+func (m *fileMap) Get() interface{} {
+	return m.text
+}
+
+// Set is part of the flag.Value interface.
+func (m *fileMap) Set(value string) error {
+	parsed, err := parseFileMap(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	*m = parsed
+	return nil
+}
+
+// parseFileMap parses a comma-separated list of "single", "single+error" and
+// SEVERITY=FILE[+FILE...] entries, such as "single,WARNING=INFO+WARNING".
+func parseFileMap(value string) (fileMap, error) {
+	m := fileMap{text: value}
+	if value == "" {
+		return m, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		switch entry {
+		case "single":
+			m.single = true
+			continue
+		case "single+error":
+			m.single, m.errorFile = true, true
+			continue
+		}
+		patAndFiles := strings.Split(entry, "=")
+		if len(patAndFiles) != 2 {
+			return fileMap{}, fmt.Errorf("log: invalid file mapping %q: want single, single+error or SEVERITY=FILE+FILE", entry)
+		}
+		sev, ok := severityByName(patAndFiles[0])
+		if !ok {
+			return fileMap{}, fmt.Errorf("log: unknown severity %q in file mapping", patAndFiles[0])
+		}
+		files := []severity{}
+		for _, name := range strings.Split(patAndFiles[1], "+") {
+			if name == "" || name == "NONE" {
+				continue
+			}
+			file, ok := severityByName(name)
+			if !ok {
+				return fileMap{}, fmt.Errorf("log: unknown file %q in file mapping", name)
+			}
+			files = append(files, file)
+		}
+		m.override[sev] = files
+	}
+	return m, nil
+}
+
+// appendFileTargets appends the severities of the files that records of
+// severity sev are written to.
+// l.mu is held.
+func (l *loggingT) appendFileTargets(dst []severity, sev severity) []severity {
+	m := &l.fileMap
+	if files := m.override[sev]; files != nil {
+		return append(dst, files...)
+	}
+	if m.single {
+		dst = append(dst, infoLog)
+		if m.errorFile && sev >= errorLog {
+			dst = append(dst, errorLog)
+		}
+		return dst
+	}
+	lowest := infoLog
+	if l.debugFiles {
+		lowest = traceLog
+	}
+	if sev < lowest {
+		sev = lowest
+	}
+	for s := sev; s >= lowest; s-- {
+		dst = append(dst, s)
+	}
+	return dst
+}
@@ -0,0 +1,106 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileTargets(t *testing.T) {
+	for _, each := range []struct {
+		spec       string
+		debugFiles bool
+		sev        severity
+		want       []severity
+	}{
+		{"", false, errorLog, []severity{errorLog, warningLog, infoLog}},
+		{"", false, debugLog, []severity{infoLog}},
+		{"", true, debugLog, []severity{debugLog, traceLog}},
+		{"single", false, fatalLog, []severity{infoLog}},
+		{"single", true, traceLog, []severity{infoLog}},
+		{"single+error", false, warningLog, []severity{infoLog}},
+		{"single+error", false, fatalLog, []severity{infoLog, errorLog}},
+		{"single,WARNING=INFO+WARNING", false, warningLog, []severity{infoLog, warningLog}},
+		{"DEBUG=NONE", false, debugLog, []severity{}},
+	} {
+		m, err := parseFileMap(each.spec)
+		if err != nil {
+			t.Fatalf("%q: %v", each.spec, err)
+		}
+		l := &loggingT{fileMap: m, debugFiles: each.debugFiles}
+		if got := l.appendFileTargets([]severity{}, each.sev); !reflect.DeepEqual(got, each.want) {
+			t.Errorf("%q %v: got %v want %v", each.spec, severityName[each.sev], got, each.want)
+		}
+	}
+	for _, spec := range []string{"combined", "INFO", "LOUD=INFO", "INFO=LOUD"} {
+		if _, err := parseFileMap(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
+
+// go test -v -test.run TestSingleFile ...glog
+func TestSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := New(Options{LogDir: dir, Files: "single+error", StderrThreshold: "FATAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("info")
+	logger.Warning("warning")
+	logger.Error("error")
+	logger.Flush()
+
+	if logger.l.file[warningLog] != nil || logger.l.file[fatalLog] != nil {
+		t.Error("unexpected WARNING or FATAL file")
+	}
+	info := readFile(t, logger.l.file[infoLog].(*syncBuffer).file.Name())
+	for _, each := range []string{"] info\n", "] warning\n", "] error\n"} {
+		if strings.Count(info, each) != 1 {
+			t.Errorf("want %q once in %q", each, info)
+		}
+	}
+	errors := readFile(t, logger.l.file[errorLog].(*syncBuffer).file.Name())
+	if !strings.Contains(errors, "] error\n") || strings.Contains(errors, "warning") {
+		t.Errorf("unexpected ERROR file: %q", errors)
+	}
+}
+
+// go test -v -test.run TestSingleFileTemplate ...glog
+func TestSingleFileTemplate(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := New(Options{LogDir: dir, Files: "single+error", FileTemplate: "{program}-{date}.log", StderrThreshold: "FATAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Error("error")
+	logger.Flush()
+	info := filepath.Base(logger.l.file[infoLog].(*syncBuffer).file.Name())
+	errors := filepath.Base(logger.l.file[errorLog].(*syncBuffer).file.Name())
+	if info == errors {
+		t.Fatalf("INFO and ERROR share the file %q", info)
+	}
+	matcher := logger.l.fileTemplate.matcher("INFO")
+	if !matcher.MatchString(info) || matcher.MatchString(errors) {
+		t.Errorf("INFO matcher %v for %q and %q", matcher, info, errors)
+	}
+}
@@ -0,0 +1,188 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Formats of the log lines written to standard error and the log files.
+
+package glog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// format is the encoding of log lines.
+type format int8
+
+const (
+	textFormat   format = iota // [IWEF]mmdd hh:mm:ss.uuuuuu threadid file:line] msg k=v, as the C++ library.
+	logfmtFormat               // ts=... level=INFO threadid=... caller=file:line msg="..." k=v
+	jsonFormat                 // {"ts":"...","level":"INFO","threadid":...,"caller":"file:line","msg":"...","k":"v"}
+)
+
+var formatName = []string{
+	textFormat:   "text",
+	logfmtFormat: "logfmt",
+	jsonFormat:   "json",
+}
+
+// formatMap holds the format per destination.
+// It is the type of the -log_format flag.
+type formatMap struct {
+	text   string
+	stderr format
+	file   format
+}
+
+func (m *formatMap) String() string {
+	return m.text
+}
+
+// Get is part of the flag.Value interface.
+func (m *formatMap) Get() interface{} {
+	return m.text
+}
+
+// Set is part of the flag.Value interface.
+func (m *formatMap) Set(value string) error {
+	parsed, err := parseFormat(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	*m = parsed
+	return nil
+}
+
+// parseFormat parses a comma-separated list of formats, such as "logfmt" or
+// "text,stderr=logfmt". A format without destination applies to both stderr and
+// the files; later entries override earlier ones. The empty string is "text".
+func parseFormat(value string) (formatMap, error) {
+	m := formatMap{text: value}
+	if value == "" {
+		return m, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		dest, name := "", entry
+		if i := strings.IndexByte(entry, '='); i >= 0 {
+			dest, name = entry[:i], entry[i+1:]
+		}
+		f := format(-1)
+		for i, each := range formatName {
+			if name == each {
+				f = format(i)
+			}
+		}
+		if f < 0 {
+			return formatMap{}, fmt.Errorf("log: unknown format %q: want %s", name, strings.Join(formatName, ", "))
+		}
+		switch dest {
+		case "":
+			m.stderr, m.file = f, f
+		case "stderr":
+			m.stderr = f
+		case "file":
+			m.file = f
+		default:
+			return formatMap{}, fmt.Errorf("log: unknown destination %q in format: want stderr or file", dest)
+		}
+	}
+	return m, nil
+}
+
+// data returns the record encoded in format f. The encoding is kept in the
+// record for the other destinations. l.mu is held.
+func (l *loggingT) data(r *Record, f format) []byte {
+	if f == textFormat {
+		return r.Data
+	}
+	if r.encoded == nil || r.encodedAs != f {
+		if r.encoded == nil {
+			r.encoded = l.getBuffer()
+		}
+		r.encoded.Reset()
+		r.encodedAs = f
+		if f == jsonFormat {
+			l.writeJSONLine(r.encoded, r)
+		} else {
+			l.writeLogfmt(r.encoded, r)
+		}
+	}
+	return r.encoded.Bytes()
+}
+
+// structuredKeys are the keys of logfmt and JSON lines that fields of a record cannot overwrite.
+var structuredKeys = []string{"ts", "level", "threadid", "caller", "msg", "stack"}
+
+// writeLogfmt writes the record as a logfmt line. The stack trace of a
+// FATAL record or of -log_backtrace_at is the quoted value of the stack key.
+func (l *loggingT) writeLogfmt(buf *buffer, r *Record) {
+	buf.WriteString("ts=")
+	buf.Write(l.appendTimestamp(buf.tmp[:0], r.Time))
+	buf.WriteString(" level=")
+	buf.WriteString(r.Severity)
+	buf.WriteString(" threadid=")
+	buf.WriteString(strconv.Itoa(r.ThreadID))
+	buf.WriteString(" caller=")
+	writeFieldValue(&buf.Buffer, r.File+":"+strconv.Itoa(r.Line))
+	buf.WriteString(" msg=")
+	writeFieldValue(&buf.Buffer, r.Message)
+	for _, f := range r.Fields {
+		buf.WriteByte(' ')
+		writeFieldKey(&buf.Buffer, fieldKey(f.Key, structuredKeys))
+		buf.WriteByte('=')
+		writeFieldValue(&buf.Buffer, fieldString(f.Value))
+	}
+	if stack := r.stack(); stack != nil {
+		buf.WriteString(" stack=")
+		writeFieldValue(&buf.Buffer, string(stack))
+	}
+	buf.WriteByte('\n')
+}
+
+// appendTimestamp appends t in RFC 3339 for structured formats, with the
+// precision of -log_time_nanos, in UTC if -log_time_utc is set.
+func (l *loggingT) appendTimestamp(dst []byte, t time.Time) []byte {
+	if l.timeUTC {
+		t = t.UTC()
+	}
+	if l.timeNanos {
+		return t.AppendFormat(dst, "2006-01-02T15:04:05.000000000Z07:00")
+	}
+	return t.AppendFormat(dst, "2006-01-02T15:04:05.000000Z07:00")
+}
+
+// fileLineFormat describes the log lines for the header of log files.
+func (l *loggingT) fileLineFormat() string {
+	switch l.format.file {
+	case logfmtFormat:
+		return "logfmt: ts level threadid caller msg key..."
+	case jsonFormat:
+		return "JSON: ts level threadid caller msg key..."
+	}
+	return l.lineFormat()
+}
+
+// statsFormat is the format of the records counted by Stats: that of the log
+// files, or of standard error with -logtostderr.
+func (l *loggingT) statsFormat() format {
+	if l.toStderr {
+		return l.format.stderr
+	}
+	return l.format.file
+}
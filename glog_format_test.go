@@ -0,0 +1,161 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFormat(t *testing.T) {
+	for _, each := range []struct {
+		value        string
+		stderr, file format
+	}{
+		{"", textFormat, textFormat},
+		{"logfmt", logfmtFormat, logfmtFormat},
+		{"stderr=logfmt", logfmtFormat, textFormat},
+		{"logfmt,file=text", logfmtFormat, textFormat},
+		{"stderr=logfmt,file=json", logfmtFormat, jsonFormat},
+	} {
+		m, err := parseFormat(each.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.stderr != each.stderr || m.file != each.file {
+			t.Errorf("%q: got %v %v", each.value, m.stderr, m.file)
+		}
+	}
+	for _, bad := range []string{"xml", "syslog=logfmt", "file=", "logfmt,"} {
+		if _, err := parseFormat(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestLogfmt(t *testing.T) {
+	zone := time.FixedZone("CET", 3600)
+	r := &Record{
+		Time:     time.Date(2006, 1, 2, 15, 4, 5, 678901234, zone),
+		Severity: "WARNING",
+		ThreadID: 1234,
+		File:     "file.go",
+		Line:     56,
+		Message:  `say "hi"` + "\nbye",
+		Fields: []Field{
+			{"id", "a4f2"},
+			{"empty", ""},
+			{"a=b c", 1},
+			{"err", errors.New("no such file")},
+			{"eq", "x=y"},
+			{"utf8", "héllo"},
+			{"bad", "\xff"},
+			{"nil", nil},
+		},
+	}
+	l := &loggingT{}
+	buf := l.getBuffer()
+	l.writeLogfmt(buf, r)
+	want := `ts=2006-01-02T15:04:05.678901+01:00 level=WARNING threadid=1234 caller=file.go:56 msg="say \"hi\"\nbye"` +
+		` id=a4f2 empty="" a_b_c=1 err="no such file" eq="x=y" utf8=héllo bad="\xff" nil=<nil>` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	buf.Reset()
+	l.timeUTC, l.timeNanos = true, true
+	r.Fields, r.Stack = nil, []byte("goroutine 1 [running]:\n")
+	l.writeLogfmt(buf, r)
+	want = `ts=2006-01-02T14:04:05.678901234Z level=WARNING threadid=1234 caller=file.go:56 msg="say \"hi\"\nbye" stack="goroutine 1 [running]:\n"` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestLogfmtReservedKeys(t *testing.T) {
+	r := &Record{Time: timeNow(), Severity: "INFO", File: "file.go", Line: 1, Message: "x",
+		Fields: []Field{{"level", "debug"}, {"msg", "y"}, {"ts", 1}, {"caller", "z"}, {"stack", ""}}}
+	l := &loggingT{}
+	buf := l.getBuffer()
+	l.writeLogfmt(buf, r)
+	want := ` level=INFO threadid=0 caller=file.go:1 msg=x fields.level=debug fields.msg=y fields.ts=1 fields.caller=z fields.stack=""` + "\n"
+	if got := buf.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("got  %q\nwant suffix %q", got, want)
+	}
+}
+
+// go test -v -test.run TestLogfmtStats ...glog
+func TestLogfmtStats(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func(previous formatMap) { logging.format = previous }(logging.format)
+	logging.format, _ = parseFormat("file=logfmt")
+	bytes := Stats.Info.Bytes()
+	Info("counted")
+	if got, want := Stats.Info.Bytes()-bytes, int64(len(contents(infoLog))); got != want {
+		t.Errorf("got %d bytes want %d", got, want)
+	}
+}
+
+// go test -v -test.run TestLogfmtFile ...glog
+func TestLogfmtFile(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func(previous formatMap) { logging.format = previous }(logging.format)
+	logging.format, _ = parseFormat("file=logfmt")
+	Infow("request done", "status", 200)
+	if want := ` level=INFO threadid=`; !contains(infoLog, want, t) {
+		t.Errorf("missing %q in %q", want, contents(infoLog))
+	}
+	if want := ` caller=glog_format_test.go:`; !contains(infoLog, want, t) {
+		t.Errorf("missing %q in %q", want, contents(infoLog))
+	}
+	if want := ` msg="request done" status=200` + "\n"; !contains(infoLog, want, t) {
+		t.Errorf("missing %q in %q", want, contents(infoLog))
+	}
+}
+
+// go test -v -test.run TestLogfmtStderr ...glog
+func TestLogfmtStderr(t *testing.T) {
+	dir := t.TempDir()
+	stderr, err := os.Create(dir + "/stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(previous *os.File) { os.Stderr = previous }(os.Stderr)
+	os.Stderr = stderr
+	l, err := New(Options{LogDir: dir, Format: "stderr=logfmt", AlsoToStderr: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Info("hello")
+	l.Close()
+	stderr.Close()
+	data, err := ioutil.ReadFile(dir + "/stderr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); !strings.HasPrefix(got, "ts=") || !strings.HasSuffix(got, " msg=hello\n") {
+		t.Errorf("got %q on stderr", got)
+	}
+	if _, err := New(Options{Format: "xml"}); err == nil {
+		t.Error("expected error")
+	}
+}
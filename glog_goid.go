@@ -0,0 +1,43 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"runtime"
+)
+
+// goroutinePrefix starts the first line of the stack of a goroutine.
+const goroutinePrefix = "goroutine "
+
+// goroutineID returns the ID of the calling goroutine, as shown in stack traces,
+// or 0 if it cannot be determined. It formats the top of the stack, which costs
+// more than the rest of the header; see BenchmarkHeaderGoroutineID.
+func goroutineID() int {
+	var b [64]byte
+	stack := b[:runtime.Stack(b[:], false)]
+	if len(stack) < len(goroutinePrefix) || string(stack[:len(goroutinePrefix)]) != goroutinePrefix {
+		return 0
+	}
+	id := 0
+	for _, c := range stack[len(goroutinePrefix):] {
+		if c < '0' || c > '9' {
+			break
+		}
+		id = id*10 + int(c-'0')
+	}
+	return id
+}
@@ -0,0 +1,56 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestGoroutineID(t *testing.T) {
+	id := goroutineID()
+	if id <= 0 {
+		t.Fatalf("got goroutine ID %d", id)
+	}
+	other := make(chan int)
+	go func() { other <- goroutineID() }()
+	if got := <-other; got <= 0 || got == id {
+		t.Errorf("got %d for another goroutine, %d for this one", got, id)
+	}
+}
+
+// go test -v -test.run TestGoroutineIDHeader ...glog
+func TestGoroutineIDHeader(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	defer func(previous bool) { logging.goroutineID = previous }(logging.goroutineID)
+	logging.goroutineID = true
+	Info("test")
+	if want := fmt.Sprintf(" %05d glog_goid_test.go:", goroutineID()); !contains(infoLog, want, t) {
+		t.Errorf("missing %q in %q", want, contents(infoLog))
+	}
+}
+
+func TestWideThreadID(t *testing.T) {
+	r := &Record{Time: timeNow(), ThreadID: 1234567, File: "file.go", Line: 1, sev: infoLog}
+	buf := logging.formatHeader(r)
+	defer logging.putBuffer(buf)
+	if want := " 1234567 file.go:1] "; !strings.Contains(buf.String(), want) {
+		t.Errorf("missing %q in %q", want, buf.String())
+	}
+}
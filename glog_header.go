@@ -0,0 +1,55 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+)
+
+// fileHeaders holds the functions added by AddFileHeader, maintained under fileHeadersMu.
+var (
+	fileHeadersMu sync.RWMutex
+	fileHeaders   []func() string
+)
+
+// AddFileHeader adds a line, such as the build version or git commit, to the
+// header written at the start of each log file of all Loggers. The function
+// is called for each new file; it must not log and may return "" to add nothing.
+// Lines are added in order of registration after the built-in lines.
+func AddFileHeader(line func() string) {
+	fileHeadersMu.Lock()
+	defer fileHeadersMu.Unlock()
+	fileHeaders = append(fileHeaders, line)
+}
+
+// writeFileHeaders writes the lines of the functions added by AddFileHeader to buf.
+func writeFileHeaders(buf *bytes.Buffer) {
+	fileHeadersMu.RLock()
+	defer fileHeadersMu.RUnlock()
+	for _, line := range fileHeaders {
+		text := line()
+		if text == "" {
+			continue
+		}
+		buf.WriteString(text)
+		if !strings.HasSuffix(text, "\n") {
+			buf.WriteByte('\n')
+		}
+	}
+}
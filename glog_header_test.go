@@ -0,0 +1,56 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"strings"
+	"testing"
+)
+
+func testFileHeader(t *testing.T, opts Options) string {
+	opts.LogDir = t.TempDir()
+	opts.StderrThreshold = "FATAL"
+	logger, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("x")
+	logger.Flush()
+	return readFile(t, logger.l.file[infoLog].(*syncBuffer).file.Name())
+}
+
+// go test -v -test.run TestAddFileHeader ...glog
+func TestAddFileHeader(t *testing.T) {
+	defer func(previous []func() string) { fileHeaders = previous }(fileHeaders)
+	AddFileHeader(func() string { return "Build version: 1.2.3" })
+	AddFileHeader(func() string { return "" })
+	AddFileHeader(func() string { return "Git commit: 4f40578\n" })
+
+	got := testFileHeader(t, Options{})
+	want := "msg\nBuild version: 1.2.3\nGit commit: 4f40578\nI"
+	if !strings.HasPrefix(got, "Log file created at: ") || !strings.Contains(got, want) {
+		t.Errorf("missing %q in %q", want, got)
+	}
+}
+
+// go test -v -test.run TestNoFileHeader ...glog
+func TestNoFileHeader(t *testing.T) {
+	if got := testFileHeader(t, Options{NoFileHeader: true}); !strings.HasPrefix(got, "I") || strings.Count(got, "\n") != 1 {
+		t.Errorf("got %q want a single log line", got)
+	}
+}
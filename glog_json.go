@@ -19,7 +19,10 @@ package glog
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
+	"strconv"
+	"unicode/utf8"
 )
 
 /*
@@ -36,34 +39,27 @@ import (
 }
 */
 
-// glogJSON can decode a data slice generated by glog and encode it in logstash json format.
+// glogJSON can encode a glog Record in logstash json format.
 // https://gist.github.com/jordansissel/2996677
 type glogJSON struct {
 	writer  *bytes.Buffer // for the composition of one message.
 	encoder *json.Encoder // used to encode string parameters.
 }
 
-// WriteWithStack decodes the data and writes a logstash json event
-func (d glogJSON) WriteWithStack(data []byte, stack []byte) {
-	d.openEvent()
-	// peek for normal logline
-	sev := data[0]
-	switch sev {
-	case 73, 87, 69, 70: // IWEF
-		d.iwef(sev, data, stack)
-	default:
-		d.message(string(data))
-	}
+// WriteRecord writes a logstash json event for the record, with its stack if any.
+func (d glogJSON) WriteRecord(r *Record) {
+	d.openEvent(r)
+	d.fields(r)
+	d.message(r.Message)
 	d.closeHash()
 }
 
 // openEvent writes the "header" part of the JSON message.
-func (d glogJSON) openEvent() {
+func (d glogJSON) openEvent(r *Record) {
 	io.WriteString(d.writer, `{"@source_host":`)
 	d.encoder.Encode(host) // uses glog package var
 	io.WriteString(d.writer, `,"@timestamp":`)
-	// ignore time information given, take new snapshot
-	d.encoder.Encode(timeNow()) // use testable function stored in var
+	d.encoder.Encode(r.Time)
 }
 
 // closeAll writes the closing brackets for the main and fields hash.
@@ -83,69 +79,111 @@ func (d glogJSON) stacktrace(stacktrace []byte) {
 	d.encoder.Encode(string(stacktrace))
 }
 
-// iwef decodes a glog data packet and write the JSON representation.
-// [IWEF]mmdd hh:mm:ss.uuuuuu threadid file:line] msg
-func (d glogJSON) iwef(sev byte, data []byte, trace []byte) {
+// fields writes the @fields hash with the location of the record, its stack,
+// the ExtraFields and the fields of the record.
+func (d glogJSON) fields(r *Record) {
 	io.WriteString(d.writer, `,"@fields":{"level":"`)
-	switch sev {
-	case 73:
-		io.WriteString(d.writer, "INFO")
-	case 87:
-		io.WriteString(d.writer, "WARNING")
-	case 69:
-		io.WriteString(d.writer, "ERROR")
-	case 70:
-		io.WriteString(d.writer, "FATAL")
-	}
-	r := &iwefreader{data, 22} // past last u
-	io.WriteString(d.writer, `","threadid":"`)
-	io.WriteString(d.writer, r.stringUpTo(32)) // space
-	r.skip()                                   // space
-	io.WriteString(d.writer, `","file":"`)
-	io.WriteString(d.writer, r.stringUpTo(58)) // :
-	r.skip()                                   // :
-	io.WriteString(d.writer, `","line":`)
-	io.WriteString(d.writer, r.stringUpTo(93)) // ]
-	// ]
-	r.skip()
-	// space
-	r.skip()
-	if trace != nil && len(trace) > 0 {
-		d.stacktrace(trace)
+	io.WriteString(d.writer, r.Severity)
+	io.WriteString(d.writer, `"`)
+	d.field("threadid", paddedThreadID(r.ThreadID))
+	d.field("file", r.File)
+	d.field("line", r.Line)
+	if len(r.Stack) > 0 {
+		d.stacktrace(r.Stack)
 	}
 	// extras?
 	for k, v := range ExtraFields {
-		io.WriteString(d.writer, `,"`)
-		io.WriteString(d.writer, k)
-		io.WriteString(d.writer, `":`)
-		d.encoder.Encode(v)
+		d.field(k, v)
+	}
+	for _, f := range r.Fields {
+		d.field(fieldKey(f.Key, logstashKeys), f.Value)
 	}
-	// fields
 	d.closeHash()
-	d.message(r.stringUpToLineEnd())
 }
 
-// iwefreader is a small helper object to parse a glog IWEF entry
-type iwefreader struct {
-	data     []byte
-	position int // read offset in data
+// logstashKeys are the keys of @fields that fields of a record cannot overwrite.
+var logstashKeys = []string{"level", "threadid", "file", "line", "stack"}
+
+// paddedThreadID returns id zero-padded to five digits, as in the header.
+func paddedThreadID(id int) string {
+	s := strconv.Itoa(id)
+	if len(s) < 5 {
+		s = "00000"[len(s):] + s
+	}
+	return s
 }
 
-// skip advances the position in data
-func (i *iwefreader) skip() {
-	i.position++
+// field writes a JSON encoded key and value.
+func (d glogJSON) field(key string, value interface{}) {
+	io.WriteString(d.writer, `,`)
+	writeJSONString(d.writer, key)
+	io.WriteString(d.writer, `:`)
+	writeJSONValue(d.writer, value)
 }
 
-// stringUpToLineEnd returns the string part from the data up to not-including the line end.
-func (i iwefreader) stringUpToLineEnd() string {
-	return string(i.data[i.position : len(i.data)-1]) // without the line delimiter
+// writeJSONValue writes the JSON encoding of a field value. Errors and
+// Stringers are written as their text, as by fmt, and so are values that
+// cannot be encoded.
+func writeJSONValue(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case string:
+		writeJSONString(buf, v)
+	case error, fmt.Stringer:
+		writeJSONString(buf, fieldString(v))
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			writeJSONString(buf, fieldString(v))
+			return
+		}
+		buf.Write(data)
+	}
 }
 
-// stringUpTo returns the string part from the data up to not-including a delimiter.
-func (i *iwefreader) stringUpTo(delim byte) string {
-	start := i.position
-	for i.data[i.position] != delim {
-		i.position++
+// writeJSONString writes s as a JSON string. Unlike encoding/json, it does
+// not escape <, > and &. Invalid UTF-8 is replaced by U+FFFD.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			switch {
+			case r == utf8.RuneError && size == 1:
+				buf.WriteString(`\ufffd`)
+			case r == '\u2028' || r == '\u2029': // for JavaScript
+				buf.WriteString(`\u202`)
+				buf.WriteByte(hexDigits[r&0xf])
+			default:
+				buf.WriteString(s[i : i+size])
+			}
+			i += size
+			continue
+		}
+		switch c {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < ' ' {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[c>>4])
+				buf.WriteByte(hexDigits[c&0xf])
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+		i++
 	}
-	return string(i.data[start:i.position])
+	buf.WriteByte('"')
 }
+
+const hexDigits = "0123456789abcdef"
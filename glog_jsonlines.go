@@ -0,0 +1,50 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// JSON Lines format of log lines, with the same keys as logfmt:
+//
+//	{"ts":"2006-01-02T15:04:05.678901+01:00","level":"INFO","threadid":1234,"caller":"file.go:10","msg":"hello","k":"v"}
+
+package glog
+
+import "strconv"
+
+// writeJSONLine writes the record as a JSON object on one line. The fields of
+// the record follow msg; the stack trace of a FATAL record or of
+// -log_backtrace_at is the value of the stack key.
+func (l *loggingT) writeJSONLine(buf *buffer, r *Record) {
+	buf.WriteString(`{"ts":"`)
+	buf.Write(l.appendTimestamp(buf.tmp[:0], r.Time))
+	buf.WriteString(`","level":"`)
+	buf.WriteString(r.Severity)
+	buf.WriteString(`","threadid":`)
+	buf.WriteString(strconv.Itoa(r.ThreadID))
+	buf.WriteString(`,"caller":`)
+	writeJSONString(&buf.Buffer, r.File+":"+strconv.Itoa(r.Line))
+	buf.WriteString(`,"msg":`)
+	writeJSONString(&buf.Buffer, r.Message)
+	for _, f := range r.Fields {
+		buf.WriteByte(',')
+		writeJSONString(&buf.Buffer, fieldKey(f.Key, structuredKeys))
+		buf.WriteByte(':')
+		writeJSONValue(&buf.Buffer, f.Value)
+	}
+	if stack := r.stack(); stack != nil {
+		buf.WriteString(`,"stack":`)
+		writeJSONString(&buf.Buffer, string(stack))
+	}
+	buf.WriteString("}\n")
+}
@@ -0,0 +1,134 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONLine(t *testing.T) {
+	zone := time.FixedZone("CET", 3600)
+	r := &Record{
+		Time:     time.Date(2006, 1, 2, 15, 4, 5, 678901234, zone),
+		Severity: "ERROR",
+		ThreadID: 1234,
+		File:     "file.go",
+		Line:     56,
+		Message:  "<a> & \"b\"\nc",
+		Fields: []Field{
+			{"status", 200},
+			{"err", errors.New("no such file")},
+			{"ch", make(chan int)},
+			{"tags", []string{"x", "y"}},
+			{"nil", nil},
+		},
+		Stack: []byte("goroutine 1 [running]:\n"),
+	}
+	l := &loggingT{}
+	buf := l.getBuffer()
+	l.writeJSONLine(buf, r)
+	want := `{"ts":"2006-01-02T15:04:05.678901+01:00","level":"ERROR","threadid":1234,"caller":"file.go:56",` +
+		`"msg":"<a> & \"b\"\nc","status":200,"err":"no such file","ch":"` + fieldString(r.Fields[2].Value) + `",` +
+		`"tags":["x","y"],"nil":null,"stack":"goroutine 1 [running]:\n"}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	if !json.Valid(buf.Bytes()) {
+		t.Errorf("invalid JSON %q", buf.String())
+	}
+}
+
+// nilStringer panics in String for a nil pointer.
+type nilStringer struct{ s string }
+
+func (n *nilStringer) String() string { return n.s }
+
+func TestJSONValues(t *testing.T) {
+	var nilErr *os.PathError
+	r := &Record{Severity: "INFO", File: "file.go", Line: 1, Message: "x",
+		Fields: []Field{
+			{"level", "debug"}, {"msg", "y"}, {"ts", 1}, {"caller", "z"}, {"threadid", 2}, {"stack", "s"},
+			{"nilerr", nilErr}, {"nilstringer", (*nilStringer)(nil)},
+		}}
+	l := &loggingT{}
+	buf := l.getBuffer()
+	l.writeJSONLine(buf, r)
+	want := `"msg":"x","fields.level":"debug","fields.msg":"y","fields.ts":1,"fields.caller":"z","fields.threadid":2,` +
+		`"fields.stack":"s","nilerr":"<nil>","nilstringer":"<nil>"}` + "\n"
+	if got := buf.String(); !strings.HasSuffix(got, want) {
+		t.Errorf("got  %q\nwant suffix %q", got, want)
+	}
+}
+
+func TestWriteJSONString(t *testing.T) {
+	for _, each := range []string{"", "plain", "<a&b>", "quote\" back\\slash", "\n\r\t\x00\x1f", "héllo", "\xff", "\u2028\u2029"} {
+		buf := new(bytes.Buffer)
+		writeJSONString(buf, each)
+		var got string
+		if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+			t.Fatalf("%q: %v in %s", each, err, buf.String())
+		}
+		if want := strings.ToValidUTF8(each, "\ufffd"); got != want {
+			t.Errorf("got %q want %q", got, want)
+		}
+	}
+	buf := new(bytes.Buffer)
+	writeJSONString(buf, "<a&b>")
+	if got := buf.String(); got != `"<a&b>"` {
+		t.Errorf("got %s", got)
+	}
+}
+
+// go test -v -test.run TestJSONLinesFile ...glog
+func TestJSONLinesFile(t *testing.T) {
+	dir := t.TempDir()
+	l, err := New(Options{LogDir: dir, Format: "json", NoFileHeader: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.With("request", "a4f2").Infow("done", "status", 200)
+	l.Warning("careful")
+	name := l.l.file[infoLog].(*syncBuffer).file.Name()
+	l.Close()
+	lines := strings.Split(strings.TrimSuffix(readFile(t, name), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines: %q", len(lines), lines)
+	}
+	for i, want := range []map[string]interface{}{
+		{"level": "INFO", "msg": "done", "request": "a4f2", "status": 200.0},
+		{"level": "WARNING", "msg": "careful"},
+	} {
+		var got map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &got); err != nil {
+			t.Fatalf("%v in %q", err, lines[i])
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s: got %v want %v in %q", k, got[k], v, lines[i])
+			}
+		}
+		if caller, _ := got["caller"].(string); !strings.HasPrefix(caller, "glog_jsonlines_test.go:") {
+			t.Errorf("got caller %q", caller)
+		}
+	}
+}
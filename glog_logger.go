@@ -0,0 +1,360 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Options configures a Logger created by New. The zero value logs to files
+// in os.TempDir() and copies ERROR and FATAL to standard error, like the
+// default Logger without flags.
+type Options struct {
+	LogDir          string        // If non-empty, write log files in this directory; see -log_dir.
+	LogDirFailFast  bool          // New fails if LogDir cannot be created, instead of using os.TempDir(); see -log_dir_fallback.
+	Format          string        // Format of log lines: "text", "logfmt" or "json", or per destination; see -log_format.
+	FilePath        string        // Form of the file in the header: "base", "package", "module" or "func"; see -log_file_path.
+	TimeFormat      string        // Format of the time in the header: "default", "year" or "rfc3339"; see -log_time_format.
+	TimeUTC         bool          // Write the time in the header in UTC; see -log_time_utc.
+	TimeNanos       bool          // Write the time in the header with nanoseconds; see -log_time_nanos.
+	GoroutineID     bool          // Write the goroutine ID in the threadid column; see -log_goroutine_id.
+	ErrorPolicy     ErrorPolicy   // What to do when a log file cannot be created or written; see -log_error_policy.
+	OnError         func(error)   // If set, called with errors of log files instead of reporting them on stderr; see SetOnError.
+	Durability      string        // Comma-separated list of SEVERITY=buffered, flush or sync settings; see -log_durability.
+	FlushInterval   time.Duration // Interval at which buffered log files are flushed; default 30s.
+	BufferSize      int           // Size in bytes of the buffer of each log file; default 256KB.
+	NoFileHeader    bool          // Do not write a header at the start of log files; see -log_file_header.
+	FileMode        os.FileMode   // Permission of new log files; default 0666 before the umask.
+	DirMode         os.FileMode   // Permission of a created LogDir; default 0755 before the umask.
+	ToStderr        bool          // Log to standard error instead of files; see -logtostderr.
+	AlsoToStderr    bool          // Log to standard error as well as files; see -alsologtostderr.
+	StderrThreshold string        // Logs at or above this severity go to stderr; default "ERROR".
+	Verbosity       Level         // Log level for V logs; see -v.
+	VModule         string        // Comma-separated list of pattern=N settings; see -vmodule.
+	DebugFiles      bool          // Write DEBUG and TRACE to their own files; see -log_debug_files.
+	RotateInterval  time.Duration // Also rotate log files at multiples of this since midnight; see -log_rotate_interval.
+	MaxFiles        int           // If positive, the maximum number of log files per severity; see -log_max_files.
+	MaxAge          time.Duration // If positive, log files older than this are deleted; see -log_max_age.
+	MaxTotalSize    uint64        // If positive, the maximum bytes of log files per severity; see -log_max_total_size.
+	Files           string        // Mapping of severities to log files; see -log_files.
+	FileTemplate    string        // Template for log file names; see -log_file_template.
+	Compress        bool          // Gzip log files after rotation; see -log_compress.
+	Compressor      Compressor    // If set, compress log files after rotation with it instead of gzip.
+	Sinks           []Sink        // Sinks that receive records after the built-in stderr and file sinks.
+}
+
+// Logger is a logging setup with its own log directory, verbosity and sinks.
+// The package-level functions use the default Logger, which is configured by the flags.
+// The Stats are shared by all Loggers.
+type Logger struct {
+	l *loggingT
+
+	// fields are bound by With and added to each record; text is their formatted form.
+	fields []Field
+	text   []byte
+}
+
+// defaultLogger wraps the state used by the package-level functions.
+var defaultLogger = &Logger{l: &logging}
+
+// Default returns the Logger used by the package-level functions.
+func Default() *Logger {
+	return defaultLogger
+}
+
+// New returns a Logger configured by opts.
+// Call Close to release its files and stop its periodic flushing.
+func New(opts Options) (*Logger, error) {
+	l := &loggingT{
+		toStderr:        opts.ToStderr,
+		alsoToStderr:    opts.AlsoToStderr,
+		stderrThreshold: errorLog,
+		logDir:          opts.LogDir,
+		debugFiles:      opts.DebugFiles,
+		fileMode:        fileMode(opts.FileMode),
+		dirMode:         fileMode(opts.DirMode),
+		dirFallback:     !opts.LogDirFailFast,
+		fileHeader:      !opts.NoFileHeader,
+		errorPolicy:     opts.ErrorPolicy,
+		timeUTC:         opts.TimeUTC,
+		timeNanos:       opts.TimeNanos,
+		goroutineID:     opts.GoroutineID,
+		onError:         opts.OnError,
+		rotateInterval:  rotateInterval(opts.RotateInterval),
+		retention:       retention{maxFiles: opts.MaxFiles, maxAge: opts.MaxAge, maxTotalSize: opts.MaxTotalSize},
+		compress:        opts.Compress,
+		compressor:      opts.Compressor,
+		done:            make(chan struct{}),
+	}
+	if opts.StderrThreshold != "" {
+		threshold, ok := severityByName(opts.StderrThreshold)
+		if !ok {
+			return nil, fmt.Errorf("log: unknown stderr threshold %q", opts.StderrThreshold)
+		}
+		l.stderrThreshold = threshold
+	}
+	filter, err := parseModuleSpec(opts.VModule)
+	if err != nil {
+		return nil, err
+	}
+	if l.fileMap, err = parseFileMap(opts.Files); err != nil {
+		return nil, err
+	}
+	if opts.FileTemplate != "" {
+		if l.fileTemplate, err = parseFileTemplate(opts.FileTemplate); err != nil {
+			return nil, err
+		}
+	}
+	if l.format, err = parseFormat(opts.Format); err != nil {
+		return nil, err
+	}
+	if l.filePath, err = parseFilePath(opts.FilePath); err != nil {
+		return nil, err
+	}
+	if l.timeFormat, err = parseTimeFormat(opts.TimeFormat); err != nil {
+		return nil, err
+	}
+	if l.durability, err = parseDurability(opts.Durability); err != nil {
+		return nil, err
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	l.flushInterval.changed = make(chan struct{}, 1)
+	l.flushInterval.set(opts.FlushInterval)
+	if l.bufferSize = byteCount(opts.BufferSize); l.bufferSize <= 0 {
+		l.bufferSize = defaultBufferSize
+	}
+	if l.fileMode == 0 {
+		l.fileMode = 0666
+	}
+	if l.dirMode == 0 {
+		l.dirMode = 0755
+	}
+	if opts.LogDirFailFast {
+		l.onceLogDirs.Do(func() { l.logDirsErr = l.createLogDirs() })
+		if l.logDirsErr != nil {
+			return nil, l.logDirsErr
+		}
+	}
+	l.setVState(opts.Verbosity, filter, true)
+	l.sinks = append([]Sink{&stderrSink{l}, &fileSink{l}}, opts.Sinks...)
+	go l.flushDaemon()
+	return &Logger{l: l}, nil
+}
+
+// Close flushes and closes all sinks of the Logger and stops its periodic flushing.
+// It waits for the compression of rotated log files.
+// Loggers returned by With share these with their parent.
+// The default Logger cannot be closed.
+func (lg *Logger) Close() error {
+	if lg.l.done == nil {
+		return fmt.Errorf("log: cannot close the default logger")
+	}
+	lg.l.mu.Lock()
+	defer lg.l.mu.Unlock()
+	select {
+	case <-lg.l.done:
+		return fmt.Errorf("log: logger already closed")
+	default:
+		close(lg.l.done)
+	}
+	for _, sink := range lg.l.sinks {
+		sink.Flush() // ignore error
+		sink.Close() // ignore error
+	}
+	lg.l.sinks = nil
+	lg.l.background.Wait()
+	return nil
+}
+
+// Flush flushes all pending log I/O of the Logger.
+func (lg *Logger) Flush() {
+	lg.l.lockAndFlushAll()
+	lg.l.waitSinks(asyncFlushTimeout)
+}
+
+// AddSink registers a Sink that receives all records after those already registered.
+func (lg *Logger) AddSink(s Sink) {
+	lg.l.addSink(s)
+}
+
+// RemoveSink unregisters a Sink previously added, then flushes and closes it.
+// It returns false if the sink was not registered.
+func (lg *Logger) RemoveSink(s Sink) bool {
+	return lg.l.removeSink(s)
+}
+
+// SetVerbosity changes the verbosity level of the Logger to v.
+func (lg *Logger) SetVerbosity(v Level) {
+	lg.l.mu.Lock()
+	defer lg.l.mu.Unlock()
+	lg.l.setVState(v, lg.l.vmodule.filter, false)
+}
+
+// VerboseLogger is returned by Logger.V and implements Info, Infoln and Infof
+// for that Logger. See the documentation of V for more information.
+type VerboseLogger struct {
+	enabled bool
+	lg      *Logger
+}
+
+// V reports whether verbosity of the Logger at the call site is at least the requested level.
+// See the documentation of the package-level V function for usage.
+func (lg *Logger) V(level Level) VerboseLogger {
+	return VerboseLogger{enabled: lg.l.v(level, 0), lg: lg}
+}
+
+// VDepth is like V but uses the call site depth frames above the caller of VDepth
+// to look up the -vmodule level. VDepth(0, level) is equivalent to V(level).
+func (lg *Logger) VDepth(depth int, level Level) VerboseLogger {
+	return VerboseLogger{enabled: lg.l.v(level, depth), lg: lg}
+}
+
+// Enabled reports whether logging is enabled at the level passed to V.
+func (v VerboseLogger) Enabled() bool {
+	return v.enabled
+}
+
+// Info is equivalent to Logger.Info, guarded by the value of v.
+func (v VerboseLogger) Info(args ...interface{}) {
+	if v.enabled {
+		v.lg.print(infoLog, 0, args...)
+	}
+}
+
+// InfoDepth is equivalent to Logger.InfoDepth, guarded by the value of v.
+func (v VerboseLogger) InfoDepth(depth int, args ...interface{}) {
+	if v.enabled {
+		v.lg.print(infoLog, depth, args...)
+	}
+}
+
+// Infoln is equivalent to Logger.Infoln, guarded by the value of v.
+func (v VerboseLogger) Infoln(args ...interface{}) {
+	if v.enabled {
+		v.lg.println(infoLog, 0, args...)
+	}
+}
+
+// Infof is equivalent to Logger.Infof, guarded by the value of v.
+func (v VerboseLogger) Infof(format string, args ...interface{}) {
+	if v.enabled {
+		v.lg.printf(infoLog, 0, format, args...)
+	}
+}
+
+// Info logs to the INFO log.
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func (lg *Logger) Info(args ...interface{}) {
+	lg.print(infoLog, 0, args...)
+}
+
+// InfoDepth acts as Info but uses depth to determine which call frame to log.
+// InfoDepth(0, "msg") is the same as Info("msg").
+func (lg *Logger) InfoDepth(depth int, args ...interface{}) {
+	lg.print(infoLog, depth, args...)
+}
+
+// Infoln logs to the INFO log.
+// Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
+func (lg *Logger) Infoln(args ...interface{}) {
+	lg.println(infoLog, 0, args...)
+}
+
+// Infof logs to the INFO log.
+// Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
+func (lg *Logger) Infof(format string, args ...interface{}) {
+	lg.printf(infoLog, 0, format, args...)
+}
+
+// Warning logs to the WARNING and INFO logs.
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func (lg *Logger) Warning(args ...interface{}) {
+	lg.print(warningLog, 0, args...)
+}
+
+// WarningDepth acts as Warning but uses depth to determine which call frame to log.
+// WarningDepth(0, "msg") is the same as Warning("msg").
+func (lg *Logger) WarningDepth(depth int, args ...interface{}) {
+	lg.print(warningLog, depth, args...)
+}
+
+// Warningln logs to the WARNING and INFO logs.
+// Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
+func (lg *Logger) Warningln(args ...interface{}) {
+	lg.println(warningLog, 0, args...)
+}
+
+// Warningf logs to the WARNING and INFO logs.
+// Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
+func (lg *Logger) Warningf(format string, args ...interface{}) {
+	lg.printf(warningLog, 0, format, args...)
+}
+
+// Error logs to the ERROR, WARNING, and INFO logs.
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func (lg *Logger) Error(args ...interface{}) {
+	lg.print(errorLog, 0, args...)
+}
+
+// ErrorDepth acts as Error but uses depth to determine which call frame to log.
+// ErrorDepth(0, "msg") is the same as Error("msg").
+func (lg *Logger) ErrorDepth(depth int, args ...interface{}) {
+	lg.print(errorLog, depth, args...)
+}
+
+// Errorln logs to the ERROR, WARNING, and INFO logs.
+// Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
+func (lg *Logger) Errorln(args ...interface{}) {
+	lg.println(errorLog, 0, args...)
+}
+
+// Errorf logs to the ERROR, WARNING, and INFO logs.
+// Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
+func (lg *Logger) Errorf(format string, args ...interface{}) {
+	lg.printf(errorLog, 0, format, args...)
+}
+
+// Fatal logs to the FATAL, ERROR, WARNING, and INFO logs,
+// including a stack trace of all running goroutines, then calls os.Exit(255).
+// Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
+func (lg *Logger) Fatal(args ...interface{}) {
+	lg.print(fatalLog, 0, args...)
+}
+
+// FatalDepth acts as Fatal but uses depth to determine which call frame to log.
+// FatalDepth(0, "msg") is the same as Fatal("msg").
+func (lg *Logger) FatalDepth(depth int, args ...interface{}) {
+	lg.print(fatalLog, depth, args...)
+}
+
+// Fatalln logs to the FATAL, ERROR, WARNING, and INFO logs,
+// including a stack trace of all running goroutines, then calls os.Exit(255).
+// Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
+func (lg *Logger) Fatalln(args ...interface{}) {
+	lg.println(fatalLog, 0, args...)
+}
+
+// Fatalf logs to the FATAL, ERROR, WARNING, and INFO logs,
+// including a stack trace of all running goroutines, then calls os.Exit(255).
+// Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
+func (lg *Logger) Fatalf(format string, args ...interface{}) {
+	lg.printf(fatalLog, 0, format, args...)
+}
@@ -0,0 +1,93 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// go test -v -test.run TestNewLogger ...glog
+func TestNewLogger(t *testing.T) {
+	dir := t.TempDir()
+	lg, err := New(Options{LogDir: dir, StderrThreshold: "FATAL", VModule: "glog_logger_test=2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lg.Close()
+	lg.Info("hello logger")
+	if !lg.V(2).Enabled() {
+		t.Error("V not enabled for 2 by vmodule")
+	}
+	if lg.V(3).Enabled() {
+		t.Error("V enabled for 3")
+	}
+	if V(2) {
+		t.Error("vmodule of Logger leaks into default logger")
+	}
+	lg.Flush()
+	data, err := ioutil.ReadFile(filepath.Join(dir, program+".INFO"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "glog_logger_test.go") || !strings.Contains(string(data), "] hello logger\n") {
+		t.Errorf("unexpected INFO log content:\n%s", data)
+	}
+}
+
+// go test -v -test.run TestNewLoggerSinks ...glog
+func TestNewLoggerSinks(t *testing.T) {
+	sink := new(captureSink)
+	lg, err := New(Options{ToStderr: true, Sinks: []Sink{sink}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	devnull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devnull.Close()
+	defer func(stderr *os.File) { os.Stderr = stderr }(os.Stderr)
+	os.Stderr = devnull
+	lg.Warningf("%d", 42)
+	lg.Debug("not at debug level")
+	lg.SetVerbosity(DEBUG)
+	lg.Debug("at debug level")
+	if len(sink.records) != 2 || sink.records[0].Message != "42" || sink.records[1].Message != "at debug level" {
+		t.Errorf("unexpected records: %+v", sink.records)
+	}
+	lg.Close()
+	if !sink.closed {
+		t.Error("sink was not closed")
+	}
+}
+
+// go test -v -test.run TestNewLoggerOptionErrors ...glog
+func TestNewLoggerOptionErrors(t *testing.T) {
+	if _, err := New(Options{StderrThreshold: "LOUD"}); err == nil {
+		t.Error("expected error for unknown threshold")
+	}
+	if _, err := New(Options{VModule: "glog=x"}); err == nil {
+		t.Error("expected error for vmodule syntax")
+	}
+	if err := Default().Close(); err == nil {
+		t.Error("expected error closing the default logger")
+	}
+}
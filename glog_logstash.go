@@ -22,6 +22,9 @@ import (
 	"flag"
 	"io"
 	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
 )
 
 // ExtraFields contains a set of @fields elements that can be used by the application
@@ -32,65 +35,403 @@ var ExtraFields = map[string]string{}
 // encodes it into JSON and writes it to an io.Writer.
 var logstash logstashPublisher
 
+// OverflowPolicy decides what happens to a logstash event when the queue is full.
+type OverflowPolicy int
+
+const (
+	// DropNewest discards the event that does not fit in the queue.
+	DropNewest OverflowPolicy = iota
+	// DropOldest discards the oldest queued event to make room for the new one.
+	DropOldest
+	// Block waits until the background writer makes room in the queue.
+	// Logging blocks while the Writer is slow or unavailable.
+	Block
+)
+
+// LogstashOptions configures the queue between logging and the logstash Writer.
+// Zero values are replaced by the defaults.
+type LogstashOptions struct {
+	QueueSize  int            // Maximum number of queued events; default 1024.
+	BatchSize  int            // Maximum number of events per Write; default 64.
+	MaxLatency time.Duration  // Maximum time an event waits for its batch to fill; default 1s.
+	Overflow   OverflowPolicy // What to do when the queue is full; default DropNewest.
+
+	// SpoolDir, if non-empty, is the directory where events are stored while
+	// the Writer fails. They are written again, in order, when it recovers.
+	// With -logstash_spool the default is a directory in -log_dir, as set
+	// when the Writer is set.
+	SpoolDir      string
+	MaxSpoolSize  int64         // Oldest events are evicted beyond this size; default 64MB.
+	SegmentSize   int64         // Size of each spool file; default 1MB.
+	RetryInterval time.Duration // Interval to replay spooled events without new events; default 10s.
+}
+
+// withDefaults returns the options with defaults for unset values.
+func (o LogstashOptions) withDefaults() LogstashOptions {
+	if o.QueueSize <= 0 {
+		o.QueueSize = 1024
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 64
+	}
+	if o.MaxLatency <= 0 {
+		o.MaxLatency = time.Second
+	}
+	if o.MaxSpoolSize <= 0 {
+		o.MaxSpoolSize = 64 * 1024 * 1024
+	}
+	if o.SegmentSize <= 0 {
+		o.SegmentSize = 1024 * 1024
+	}
+	if o.SegmentSize > o.MaxSpoolSize {
+		o.SegmentSize = o.MaxSpoolSize
+	}
+	if o.RetryInterval <= 0 {
+		o.RetryInterval = 10 * time.Second
+	}
+	return o
+}
+
+// defaultSpoolDir returns the spool directory used with -logstash_spool.
+// logging.mu is held.
+func defaultSpoolDir() string {
+	dir := logging.logDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, program+".logstash.spool")
+}
+
+// PublisherStats tracks the number of logstash events per outcome.
+type PublisherStats struct {
+	written  int64
+	dropped  int64
+	failed   int64
+	spooled  int64
+	replayed int64
+	evicted  int64
+}
+
+// Written returns the number of events written to the Writer.
+func (s *PublisherStats) Written() int64 {
+	return atomic.LoadInt64(&s.written)
+}
+
+// Dropped returns the number of events discarded because the queue was full.
+func (s *PublisherStats) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Failed returns the number of events the Writer failed to write and that were not spooled.
+func (s *PublisherStats) Failed() int64 {
+	return atomic.LoadInt64(&s.failed)
+}
+
+// Spooled returns the number of events stored in the spool directory.
+func (s *PublisherStats) Spooled() int64 {
+	return atomic.LoadInt64(&s.spooled)
+}
+
+// Replayed returns the number of spooled events written to the Writer.
+func (s *PublisherStats) Replayed() int64 {
+	return atomic.LoadInt64(&s.replayed)
+}
+
+// Evicted returns the number of spooled events deleted to stay within MaxSpoolSize.
+func (s *PublisherStats) Evicted() int64 {
+	return atomic.LoadInt64(&s.evicted)
+}
+
+// LogstashStats tracks the events of the logstash publisher.
+var LogstashStats PublisherStats
+
 // Set the io.Writer to write JSON. This is required if -logstash=true
+// Events are written by a background goroutine using the default LogstashOptions.
 func SetLogstashWriter(writer io.Writer) {
-	logstash.writer = newBufferedWriter(writer)
+	SetLogstashWriterOptions(writer, LogstashOptions{})
+}
+
+// SetLogstashWriterOptions sets the io.Writer to write JSON with the given queue options.
+// Events queued for the previous Writer are written before it is replaced.
+// Call it after flag.Parse for -logstash_spool to take effect.
+func SetLogstashWriterOptions(writer io.Writer, opts LogstashOptions) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	if logstash.writer != nil {
+		logstash.writer.close()
+	}
+	if opts.SpoolDir == "" && logstash.spool {
+		opts.SpoolDir = defaultSpoolDir()
+	}
+	logstash.writer = newAsyncWriter(writer, opts.withDefaults())
 }
 
 func init() {
 	flag.BoolVar(&logstash.toLogstash, "logstash", false, "log also in JSON using the Logstash writer")
+	flag.BoolVar(&logstash.spool, "logstash_spool", false, "store logstash events in -log_dir while the Logstash writer fails")
 	// Write to Stderr until SetLogstashWriter is called so we do not loose events.
 	SetLogstashWriter(os.Stderr)
+	AddSink(&logstash)
 }
 
 // logstashPublisher holds global state for publishing messages in JSON.
 type logstashPublisher struct {
-	toLogstash bool            // The -logstash flag.
-	writer     *bufferedWriter // Buffered target writer for JSON messages.
+	toLogstash bool         // The -logstash flag.
+	spool      bool         // The -logstash_spool flag.
+	writer     *asyncWriter // Queued target writer for JSON messages.
+}
+
+// Emit writes the record as logstash json event if -logstash is set.
+// Only fatal records are written with the stack trace of all goroutines.
+func (p *logstashPublisher) Emit(r *Record) error {
+	if !p.toLogstash {
+		return nil
+	}
+	p.WriteRecord(r)
+	return nil
+}
+
+// Flush starts writing all pending messages if -logstash is set. It does not
+// wait for the Writer; see wait.
+func (p *logstashPublisher) Flush() error {
+	if p.toLogstash && p.writer != nil {
+		p.writer.flush(0)
+	}
+	return nil
 }
 
-// WriteWithStack decodes the data and writes a logstash json event
-func (p logstashPublisher) WriteWithStack(data []byte, stack []byte) {
+// wait waits at most timeout until the pending messages are written.
+// It is part of the asyncSink interface.
+func (p *logstashPublisher) wait(timeout time.Duration) {
+	if p.toLogstash && p.writer != nil {
+		p.writer.flush(timeout)
+	}
+}
+
+// Close is part of the Sink interface; the writer is owned by the application.
+func (p *logstashPublisher) Close() error {
+	return nil
+}
+
+// WriteRecord encodes the record and writes a logstash json event
+func (p logstashPublisher) WriteRecord(r *Record) {
 	buffer := new(bytes.Buffer)
-	glogJSON{writer: buffer, encoder: json.NewEncoder(buffer)}.WriteWithStack(data, stack)
+	glogJSON{writer: buffer, encoder: json.NewEncoder(buffer)}.WriteRecord(r)
 	p.writer.Write(buffer.Bytes())
 }
 
-// flush waits until all pending messages are written by the asyncWriter.
-func (p logstashPublisher) flush() {
-	if p.writer != nil { // be robust
-		p.writer.flush()
+// asyncWriter queues []byte events and writes them in batches from its own goroutine.
+type asyncWriter struct {
+	writer  io.Writer
+	opts    LogstashOptions
+	events  chan []byte        // the bounded queue.
+	flushes chan chan struct{} // requests to write all queued events, acknowledged by closing; holds one.
+	done    chan struct{}      // closed to stop the goroutine after writing all queued events.
+	stopped chan struct{}      // closed when the goroutine has stopped.
+	spool   *spool             // failed events, if opts.SpoolDir is set; only used by the goroutine.
+	noSpool bool               // set if the spool cannot be opened.
+}
+
+// newAsyncWriter decorates the underlyingWriter and starts its goroutine.
+func newAsyncWriter(underlyingWriter io.Writer, opts LogstashOptions) *asyncWriter {
+	a := &asyncWriter{
+		writer:  underlyingWriter,
+		opts:    opts,
+		events:  make(chan []byte, opts.QueueSize),
+		flushes: make(chan chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Write is for implementing io.Writer. It queues the data, which must not be
+// modified afterwards, according to the OverflowPolicy.
+func (a *asyncWriter) Write(data []byte) (n int, err error) {
+	switch a.opts.Overflow {
+	case DropNewest:
+		select {
+		case a.events <- data:
+		default:
+			atomic.AddInt64(&LogstashStats.dropped, 1)
+		}
+	case DropOldest:
+		for {
+			select {
+			case a.events <- data:
+				return len(data), nil
+			default:
+			}
+			select {
+			case <-a.events:
+				atomic.AddInt64(&LogstashStats.dropped, 1)
+			default:
+			}
+		}
+	default:
+		a.events <- data
 	}
+	return len(data), nil
 }
 
-// bufferedWriter collects []byte until a flush.
-type bufferedWriter struct {
-	buffer [][]byte
-	writer io.Writer
+// flush requests to write all events queued so far and waits at most timeout
+// until they are written. Without timeout, it only requests.
+// It reports whether the events are written.
+func (a *asyncWriter) flush(timeout time.Duration) bool {
+	ack := make(chan struct{})
+	if timeout <= 0 {
+		select {
+		case a.flushes <- ack:
+		default: // a request is pending
+		}
+		return false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case a.flushes <- ack:
+	case <-a.stopped:
+		return true
+	case <-timer.C:
+		return false
+	}
+	select {
+	case <-ack:
+		return true
+	case <-a.stopped:
+		return true
+	case <-timer.C:
+		return false
+	}
 }
 
-// newBufferedWriter decorates the underlyingWriter.
-func newBufferedWriter(underlyingWriter io.Writer) *bufferedWriter {
-	bw := new(bufferedWriter)
-	bw.buffer = [][]byte{}
-	bw.writer = underlyingWriter
-	return bw
+// close writes all queued events and stops the goroutine.
+func (a *asyncWriter) close() {
+	close(a.done)
+	<-a.stopped
 }
 
-// Write is for implementing io.Writer
-func (b *bufferedWriter) Write(data []byte) (n int, err error) {
-	b.buffer = append(b.buffer, data)
-	return len(data), nil
+// batchWriter is implemented by writers that need the event boundaries of a batch,
+// such as a datagram connection. Other writers receive a batch in a single Write.
+type batchWriter interface {
+	WriteBatch(events [][]byte) error
 }
 
-// flush drains the buffer. it is called from the daemon goroutine.
-func (b *bufferedWriter) flush() {
-	for _, each := range b.buffer {
-		_, err := b.writer.Write(each)
-		if err != nil {
-			os.Stderr.WriteString("[glog error] unable to flush buffered logstash message:\n")
-			os.Stderr.WriteString(string(each))
+// run collects queued events in batches and writes a batch when it is full,
+// when its first event has waited MaxLatency or when requested.
+func (a *asyncWriter) run() {
+	defer close(a.stopped)
+	var batch [][]byte
+	var timeout, retry <-chan time.Time
+	write := func() {
+		a.write(batch)
+		batch, timeout = batch[:0], nil
+		if retry == nil && a.spool != nil && a.spool.pending() {
+			retry = time.After(a.opts.RetryInterval)
+		}
+	}
+	add := func(data []byte) {
+		if len(batch) == 0 {
+			timeout = time.After(a.opts.MaxLatency)
 		}
+		batch = append(batch, data)
+		if len(batch) >= a.opts.BatchSize {
+			write()
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case data := <-a.events:
+				add(data)
+			default:
+				write()
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case data := <-a.events:
+			add(data)
+		case <-timeout:
+			write()
+		case <-retry:
+			retry = nil
+			write()
+		case ack := <-a.flushes:
+			drain()
+			close(ack)
+		case <-a.done:
+			drain()
+			return
+		}
+	}
+}
+
+// openSpool opens the spool directory, if any, on the first write, keeping
+// events of a previous run.
+func (a *asyncWriter) openSpool() {
+	if a.spool != nil || a.noSpool || a.opts.SpoolDir == "" {
+		return
+	}
+	s, err := openSpool(a.opts.SpoolDir, a.opts.MaxSpoolSize, a.opts.SegmentSize)
+	if err != nil {
+		os.Stderr.WriteString("[glog error] unable to open logstash spool: " + err.Error() + "\n")
+		a.noSpool = true
+		return
+	}
+	a.spool = s
+}
+
+// write writes the spooled events, if any, followed by the batch of events to
+// the underlying writer. Events that cannot be written are spooled if possible.
+func (a *asyncWriter) write(batch [][]byte) {
+	a.openSpool()
+	if a.spool != nil && a.spool.pending() {
+		if err := a.spool.replay(a.writeEvents); err != nil {
+			// Keep the order; the batch waits behind the spooled events.
+			if len(batch) > 0 {
+				if n, err := a.spool.append(batch); err != nil {
+					a.failed(batch[n:], err)
+				}
+			}
+			return
+		}
+	}
+	if len(batch) == 0 {
+		return
+	}
+	err := a.writeEvents(batch)
+	if err != nil && a.spool != nil {
+		var n int
+		if n, err = a.spool.append(batch); err == nil {
+			return
+		}
+		batch = batch[n:]
+	}
+	if err != nil {
+		a.failed(batch, err)
+	} else {
+		atomic.AddInt64(&LogstashStats.written, int64(len(batch)))
+	}
+}
+
+// writeEvents writes the events to the underlying writer.
+func (a *asyncWriter) writeEvents(events [][]byte) error {
+	if bw, ok := a.writer.(batchWriter); ok {
+		return bw.WriteBatch(events)
+	}
+	_, err := a.writer.Write(bytes.Join(events, nil))
+	return err
+}
+
+// failed reports events that could not be written nor spooled on stderr.
+func (a *asyncWriter) failed(batch [][]byte, err error) {
+	atomic.AddInt64(&LogstashStats.failed, int64(len(batch)))
+	os.Stderr.WriteString("[glog error] unable to write logstash messages: " + err.Error() + "\n")
+	for _, each := range batch {
+		os.Stderr.Write(each)
 	}
-	b.buffer = [][]byte{}
 }
@@ -18,6 +18,7 @@ package glog
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"os"
 	"strings"
@@ -36,12 +37,12 @@ func TestInfoLogstash(t *testing.T) {
 	capture := new(bytes.Buffer)
 	SetLogstashWriter(capture)
 	Info("hello")
+	Flush() // wait for the asynchronous write
 	actual := capture.String()
 	if strings.HasPrefix(actual, jsonBegin) && strings.HasSuffix(actual, jsonEnd) {
 		println(actual)
 		t.Fatalf("mismatch in json")
 	}
-	Flush()
 }
 
 var jsonBegin = `{"@source_host":"unknownhost"
@@ -53,6 +54,18 @@ var jsonEnd = `"file":"glog_logstash_test.go","line":18}
 }
 `
 
+func TestLogstashRecordFields(t *testing.T) {
+	r := &Record{Time: timeNow(), Severity: "INFO", ThreadID: 2628, File: "file.go", Line: 10, Message: "hello",
+		Fields: []Field{{"level", "debug"}, {"line", 3}, {"id", "a4f2"}}}
+	buf := new(bytes.Buffer)
+	glogJSON{writer: buf, encoder: json.NewEncoder(buf)}.WriteRecord(r)
+	for _, want := range []string{`"level":"INFO"`, `"threadid":"02628"`, `"line":10`, `"fields.level":"debug"`, `"fields.line":3`, `"id":"a4f2"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %s in %s", want, buf.String())
+		}
+	}
+}
+
 // go test -v -test.run TestEnabledLogstashNoWriter ...glog
 func TestEnabledLogstashNoWriter(t *testing.T) {
 	logstash.toLogstash = true
@@ -84,6 +97,102 @@ func TestErrorWritingLogstashNoWriter(t *testing.T) {
 	logstash.toLogstash = false
 }
 
+// gateWriter blocks each Write until the gate is opened.
+type gateWriter struct {
+	entered chan struct{}
+	gate    chan struct{}
+	written bytes.Buffer
+}
+
+func newGateWriter() *gateWriter {
+	return &gateWriter{entered: make(chan struct{}, 16), gate: make(chan struct{})}
+}
+
+func (g *gateWriter) Write(p []byte) (n int, err error) {
+	g.entered <- struct{}{}
+	<-g.gate
+	return g.written.Write(p)
+}
+
+// testOverflow queues a, b, c and d for a writer that is stuck writing a, with room for two events.
+func testOverflow(t *testing.T, policy OverflowPolicy, want string) {
+	w := newGateWriter()
+	a := newAsyncWriter(w, LogstashOptions{QueueSize: 2, BatchSize: 1, Overflow: policy}.withDefaults())
+	dropped := LogstashStats.Dropped()
+	a.Write([]byte("a"))
+	<-w.entered
+	for _, each := range []string{"b", "c", "d"} {
+		a.Write([]byte(each))
+	}
+	close(w.gate)
+	a.close()
+	if got := w.written.String(); got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	if got := LogstashStats.Dropped() - dropped; got != 1 {
+		t.Errorf("got %d dropped events want 1", got)
+	}
+}
+
+// go test -v -test.run TestLogstashDropNewest ...glog
+func TestLogstashDropNewest(t *testing.T) {
+	testOverflow(t, DropNewest, "abc")
+}
+
+// go test -v -test.run TestLogstashDropOldest ...glog
+func TestLogstashDropOldest(t *testing.T) {
+	testOverflow(t, DropOldest, "acd")
+}
+
+// go test -v -test.run TestLogstashMaxLatency ...glog
+func TestLogstashMaxLatency(t *testing.T) {
+	w := newGateWriter()
+	close(w.gate)
+	a := newAsyncWriter(w, LogstashOptions{BatchSize: 10, MaxLatency: 10 * time.Millisecond}.withDefaults())
+	defer a.close()
+	a.Write([]byte("a"))
+	a.Write([]byte("b"))
+	select {
+	case <-w.entered: // one batch without flush
+	case <-time.After(5 * time.Second):
+		t.Fatal("batch not written after MaxLatency")
+	}
+	a.flush(time.Minute)
+	if got := w.written.String(); got != "ab" {
+		t.Errorf("got %q want %q", got, "ab")
+	}
+}
+
+// go test -v -test.run TestLogstashFlushDoesNotBlock ...glog
+func TestLogstashFlushDoesNotBlock(t *testing.T) {
+	w := newGateWriter()
+	logstash.toLogstash = true
+	SetLogstashWriter(w)
+	defer func() {
+		logstash.toLogstash = false
+		SetLogstashWriter(os.Stderr)
+	}()
+	Info("stuck")
+	logging.lockAndFlushAll()
+	<-w.entered
+	done := make(chan struct{})
+	go func() {
+		logging.lockAndFlushAll() // as the flush daemon
+		Info("not blocked")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("logging blocked by a logstash flush")
+	}
+	close(w.gate)
+	Flush()
+	if got := w.written.String(); !strings.Contains(got, "not blocked") {
+		t.Errorf("got %q want both events after Flush", got)
+	}
+}
+
 func ExampleSetLogstashWriter() {
 	// TODO write the UDP example
 	logstash, err := os.Create("logstash.log")
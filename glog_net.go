@@ -0,0 +1,260 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnState is the state of the connection of a NetWriter.
+type ConnState int32
+
+const (
+	Disconnected ConnState = iota // Not connected; the next write dials.
+	Connected                     // The last dial or write succeeded.
+)
+
+func (s ConnState) String() string {
+	if s == Connected {
+		return "connected"
+	}
+	return "disconnected"
+}
+
+// NetWriterOptions configures a NetWriter. Zero values are replaced by the defaults.
+type NetWriterOptions struct {
+	DialTimeout  time.Duration // Timeout for each dial; default 5s.
+	WriteTimeout time.Duration // Timeout for each write on the connection; default 5s.
+	BatchTimeout time.Duration // Time for a batch, including redials and backoff, before it fails; default 5s.
+	MinBackoff   time.Duration // Wait before the first redial; default 100ms.
+	MaxBackoff   time.Duration // Upper bound for the doubling wait between redials; default 10s.
+	Retries      int           // Redials per batch before it fails; default 3.
+
+	// OnStateChange, if set, is called after the connection state changes and
+	// when the first dial fails. It is called from the goroutine writing the
+	// events and must not log.
+	OnStateChange func(state ConnState, err error)
+}
+
+// withDefaults returns the options with defaults for unset values.
+func (o NetWriterOptions) withDefaults() NetWriterOptions {
+	if o.DialTimeout <= 0 {
+		o.DialTimeout = 5 * time.Second
+	}
+	if o.WriteTimeout <= 0 {
+		o.WriteTimeout = 5 * time.Second
+	}
+	if o.BatchTimeout <= 0 {
+		o.BatchTimeout = 5 * time.Second
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = 100 * time.Millisecond
+	}
+	if o.MaxBackoff < o.MinBackoff {
+		o.MaxBackoff = 10 * time.Second
+		if o.MaxBackoff < o.MinBackoff {
+			o.MaxBackoff = o.MinBackoff
+		}
+	}
+	if o.Retries <= 0 {
+		o.Retries = 3
+	}
+	return o
+}
+
+var (
+	errNetWriterClosed = errors.New("log: logstash network writer is closed")
+	errBatchTimeout    = errors.New("log: logstash network writer timed out")
+)
+
+// NetWriter writes logstash events to a TCP or UDP address. It dials on the
+// first write and, when a write fails, redials with exponential backoff and
+// sends the failed batch again, so events are delivered at least once.
+// A batch that is not sent within BatchTimeout fails, to be spooled if a
+// spool is set. Use it with SetLogstashWriter.
+type NetWriter struct {
+	network string
+	addr    string
+	opts    NetWriterOptions
+
+	state     int32         // ConnState, handled atomically.
+	reported  bool          // set after the first call of OnStateChange; w.mu is held.
+	done      chan struct{} // closed by Close to stop waiting for a redial.
+	closeOnce sync.Once
+
+	// mu protects the connection and serializes writes.
+	mu      sync.Mutex
+	conn    net.Conn
+	backoff time.Duration // wait before the next dial; zero after a successful write.
+	dialAt  time.Time     // when the backoff after the last failure ends.
+}
+
+// NewLogstashTCPWriter returns a NetWriter that writes events to addr over TCP.
+func NewLogstashTCPWriter(addr string, opts NetWriterOptions) *NetWriter {
+	return &NetWriter{network: "tcp", addr: addr, opts: opts.withDefaults(), done: make(chan struct{})}
+}
+
+// NewLogstashUDPWriter returns a NetWriter that writes each event to addr in its own UDP datagram.
+func NewLogstashUDPWriter(addr string, opts NetWriterOptions) *NetWriter {
+	return &NetWriter{network: "udp", addr: addr, opts: opts.withDefaults(), done: make(chan struct{})}
+}
+
+// State returns the current connection state.
+func (w *NetWriter) State() ConnState {
+	return ConnState(atomic.LoadInt32(&w.state))
+}
+
+// Write is for implementing io.Writer; p is sent as a single batch.
+func (w *NetWriter) Write(p []byte) (n int, err error) {
+	if err := w.WriteBatch([][]byte{p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WriteBatch sends the events, redialing as needed. For UDP each event is a datagram.
+// It gives up after Retries redials or BatchTimeout, whichever comes first.
+func (w *NetWriter) WriteBatch(events [][]byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	deadline := time.Now().Add(w.opts.BatchTimeout)
+	var err error
+	for attempt := 0; attempt <= w.opts.Retries; attempt++ {
+		if w.conn == nil {
+			if wait := time.Until(w.dialAt); wait > 0 {
+				if wait > time.Until(deadline) {
+					break // fail now rather than after the deadline
+				}
+				select {
+				case <-time.After(wait):
+				case <-w.done:
+				}
+			}
+			select {
+			case <-w.done:
+				return errNetWriterClosed
+			default:
+			}
+			if err = w.dial(deadline); err != nil {
+				continue
+			}
+		}
+		if err = w.send(events, deadline); err == nil {
+			w.backoff = 0
+			return nil
+		}
+		w.disconnect(err)
+	}
+	if err == nil {
+		err = errBatchTimeout
+	}
+	return err
+}
+
+// dial connects before the deadline and, on failure, doubles the backoff.
+// w.mu is held.
+func (w *NetWriter) dial(deadline time.Time) error {
+	timeout := w.opts.DialTimeout
+	if left := time.Until(deadline); left < timeout {
+		timeout = left
+	}
+	if timeout <= 0 {
+		return errBatchTimeout
+	}
+	conn, err := net.DialTimeout(w.network, w.addr, timeout)
+	if err != nil {
+		w.increaseBackoff()
+		w.setState(Disconnected, err)
+		return err
+	}
+	w.conn = conn
+	w.setState(Connected, nil)
+	return nil
+}
+
+// increaseBackoff doubles the wait before the next dial up to MaxBackoff.
+// w.mu is held.
+func (w *NetWriter) increaseBackoff() {
+	if w.backoff == 0 {
+		w.backoff = w.opts.MinBackoff
+	} else if w.backoff *= 2; w.backoff > w.opts.MaxBackoff {
+		w.backoff = w.opts.MaxBackoff
+	}
+	w.dialAt = time.Now().Add(w.backoff)
+}
+
+// send writes the events on the current connection before the deadline.
+// w.mu is held.
+func (w *NetWriter) send(events [][]byte, deadline time.Time) error {
+	if writeDeadline := time.Now().Add(w.opts.WriteTimeout); writeDeadline.Before(deadline) {
+		deadline = writeDeadline
+	}
+	w.conn.SetWriteDeadline(deadline)
+	if w.network == "udp" {
+		for _, each := range events {
+			if _, err := w.conn.Write(each); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	_, err := w.conn.Write(bytes.Join(events, nil))
+	return err
+}
+
+// disconnect closes the connection after err.
+// w.mu is held.
+func (w *NetWriter) disconnect(err error) {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+	w.increaseBackoff()
+	w.setState(Disconnected, err)
+}
+
+// setState records and reports a change of the connection state.
+// w.mu is held.
+func (w *NetWriter) setState(state ConnState, err error) {
+	if w.State() == state && w.reported {
+		return
+	}
+	w.reported = true
+	atomic.StoreInt32(&w.state, int32(state))
+	if w.opts.OnStateChange != nil {
+		w.opts.OnStateChange(state, err)
+	}
+}
+
+// Close closes the connection; subsequent writes fail.
+func (w *NetWriter) Close() error {
+	w.closeOnce.Do(func() { close(w.done) })
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	w.setState(Disconnected, nil)
+	return err
+}
@@ -0,0 +1,207 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+// go test -v -test.run TestTCPWriterRedial ...glog
+func TestTCPWriterRedial(t *testing.T) {
+	// Reserve an address that nobody listens on yet.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	states := make(chan ConnState, 4)
+	w := NewLogstashTCPWriter(addr, NetWriterOptions{
+		MinBackoff:    10 * time.Millisecond,
+		MaxBackoff:    50 * time.Millisecond,
+		Retries:       100,
+		OnStateChange: func(s ConnState, err error) { states <- s },
+	})
+	defer w.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		defer conn.Close()
+		data, _ := ioutil.ReadAll(conn)
+		received <- string(data)
+	}()
+	if err := w.WriteBatch([][]byte{[]byte("a\n"), []byte("b\n")}); err != nil {
+		t.Fatalf("write after redial failed: %v", err)
+	}
+	if got := w.State(); got != Connected {
+		t.Errorf("got state %v want %v", got, Connected)
+	}
+	w.Close()
+	if got := <-received; got != "a\nb\n" {
+		t.Errorf("got %q", got)
+	}
+	for _, want := range []ConnState{Disconnected, Connected} {
+		if got := <-states; got != want {
+			t.Errorf("got state change to %v want %v", got, want)
+		}
+	}
+	if _, err := w.Write([]byte("c\n")); err != errNetWriterClosed {
+		t.Errorf("got %v after Close", err)
+	}
+}
+
+// go test -v -test.run TestTCPWriterGivesUp ...glog
+func TestTCPWriterGivesUp(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	states := make(chan ConnState, 4)
+	w := NewLogstashTCPWriter(addr, NetWriterOptions{
+		MinBackoff:    time.Millisecond,
+		Retries:       2,
+		OnStateChange: func(s ConnState, err error) { states <- s },
+	})
+	defer w.Close()
+	if _, err := w.Write([]byte("lost\n")); err == nil {
+		t.Error("expected error without listener")
+	}
+	if got := w.State(); got != Disconnected {
+		t.Errorf("got state %v want %v", got, Disconnected)
+	}
+	select {
+	case got := <-states:
+		if got != Disconnected {
+			t.Errorf("got state change to %v want %v", got, Disconnected)
+		}
+	default:
+		t.Error("first failed dial not reported")
+	}
+}
+
+// go test -v -test.run TestTCPWriterBatchTimeout ...glog
+func TestTCPWriterBatchTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	w := NewLogstashTCPWriter(addr, NetWriterOptions{
+		BatchTimeout: 100 * time.Millisecond,
+		MinBackoff:   40 * time.Millisecond,
+		MaxBackoff:   time.Second,
+		Retries:      100,
+	})
+	defer w.Close()
+	start := time.Now()
+	if _, err := w.Write([]byte("lost\n")); err == nil {
+		t.Error("expected error without listener")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("batch took %v, want at most its BatchTimeout", elapsed)
+	}
+}
+
+// go test -v -test.run TestTCPWriterResend ...glog
+func TestTCPWriterResend(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	dropped, received := make(chan struct{}), make(chan string, 1)
+	go func() {
+		// Read the first batch, then reset the connection.
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- err.Error()
+			return
+		}
+		buf := make([]byte, 2)
+		io.ReadFull(conn, buf)
+		conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+		close(dropped)
+		if conn, err = listener.Accept(); err != nil {
+			received <- err.Error()
+			return
+		}
+		defer conn.Close()
+		data, _ := ioutil.ReadAll(conn)
+		received <- string(data)
+	}()
+	w := NewLogstashTCPWriter(listener.Addr().String(), NetWriterOptions{MinBackoff: time.Millisecond})
+	defer w.Close()
+	if _, err := w.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	<-dropped
+	time.Sleep(50 * time.Millisecond) // let the reset arrive
+	if _, err := w.Write([]byte("b\n")); err != nil {
+		t.Fatalf("write after the connection dropped failed: %v", err)
+	}
+	w.Close()
+	if got := <-received; got != "b\n" {
+		t.Errorf("got %q on the new connection want %q", got, "b\n")
+	}
+}
+
+// go test -v -test.run TestUDPWriterDatagrams ...glog
+func TestUDPWriterDatagrams(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	w := NewLogstashUDPWriter(conn.LocalAddr().String(), NetWriterOptions{})
+	defer w.Close()
+	a := newAsyncWriter(w, LogstashOptions{}.withDefaults())
+	a.Write([]byte(`{"n":1}`))
+	a.Write([]byte(`{"n":2}`))
+	a.close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 64)
+	for _, want := range []string{`{"n":1}`, `{"n":2}`} {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:n]); got != want {
+			t.Errorf("got datagram %q want %q", got, want)
+		}
+	}
+}
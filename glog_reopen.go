@@ -0,0 +1,113 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Reopening log files for external log rotation.
+
+package glog
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Reopen flushes and closes the log files of the default Logger and opens
+// files with the same names, creating them if they were moved away, for
+// example by logrotate.
+func Reopen() error {
+	return logging.reopen()
+}
+
+// ReopenOnSignal calls Reopen each time the process receives one of sigs, or SIGHUP if none.
+func ReopenOnSignal(sigs ...os.Signal) {
+	defaultLogger.ReopenOnSignal(sigs...)
+}
+
+// Reopen flushes and closes the log files of the Logger and opens files with
+// the same names, creating them if they were moved away.
+func (lg *Logger) Reopen() error {
+	return lg.l.reopen()
+}
+
+// ReopenOnSignal calls Reopen each time the process receives one of sigs, or
+// SIGHUP if none, until the Logger is closed.
+func (lg *Logger) ReopenOnSignal(sigs ...os.Signal) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+	go func() {
+		defer signal.Stop(c)
+		for {
+			select {
+			case <-c:
+				if err := lg.l.reopen(); err != nil {
+					fmt.Fprintf(os.Stderr, "[glog error] unable to reopen log files: %v\n", err)
+				}
+			case <-lg.l.done:
+				return
+			}
+		}
+	}()
+}
+
+// reopen reopens all log files, returning the first error.
+func (l *loggingT) reopen() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var firstErr error
+	for _, each := range l.file {
+		sb, ok := each.(*syncBuffer)
+		if !ok || sb.file == nil {
+			continue
+		}
+		if err := sb.reopen(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// reopen flushes and closes the file and opens it again by name, appending.
+// A new file gets a header. If the name cannot be opened, a new file is created
+// as on rotation.
+// l.mu is held.
+func (sb *syncBuffer) reopen() error {
+	name := sb.file.Name()
+	sb.Flush()
+	sb.closeFile()
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, os.FileMode(sb.logger.fileMode))
+	if err != nil {
+		sb.file = nil
+		return sb.rotateFile(timeNow())
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		sb.file = nil
+		return sb.rotateFile(timeNow())
+	}
+	sb.file = f
+	trackFile(name, 1)
+	sb.Writer.Reset(f)
+	sb.nbytes = uint64(info.Size())
+	if sb.nbytes == 0 {
+		return sb.writeHeader(timeNow())
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func readFile(t *testing.T, name string) string {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// go test -v -test.run TestReopen ...glog
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	logger, err := New(Options{LogDir: dir, StderrThreshold: "FATAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("before")
+	name := logger.l.file[infoLog].(*syncBuffer).file.Name()
+	// simulate logrotate moving the file away
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("after")
+	logger.Flush()
+
+	if got := readFile(t, name+".1"); !strings.Contains(got, "] before\n") || strings.Contains(got, "after") {
+		t.Errorf("unexpected moved file: %q", got)
+	}
+	got := readFile(t, name)
+	if !strings.HasPrefix(got, "Log file created at: ") || !strings.Contains(got, "] after\n") || strings.Contains(got, "before") {
+		t.Errorf("unexpected reopened file: %q", got)
+	}
+
+	// reopening a file that was not moved appends to it
+	if err := logger.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("appended")
+	logger.Flush()
+	if got := readFile(t, name); !strings.Contains(got, "] after\n") || !strings.Contains(got, "] appended\n") {
+		t.Errorf("unexpected file after reopen: %q", got)
+	}
+}
+
+// go test -v -test.run TestReopenOnSignal ...glog
+func TestReopenOnSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals cannot be sent on windows")
+	}
+	dir := t.TempDir()
+	logger, err := New(Options{LogDir: dir, StderrThreshold: "FATAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("before")
+	name := logger.l.file[infoLog].(*syncBuffer).file.Name()
+	logger.ReopenOnSignal(syscall.SIGHUP)
+	if err := os.Rename(name, name+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := os.FindProcess(os.Getpid()); err == nil {
+		p.Signal(syscall.SIGHUP)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(name); err == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("file not reopened after signal")
+}
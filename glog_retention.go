@@ -0,0 +1,183 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Retention of log files.
+
+package glog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// retention limits the log files kept for each severity. Zero values are unlimited.
+type retention struct {
+	maxFiles     int           // The -log_max_files flag.
+	maxAge       time.Duration // The -log_max_age flag.
+	maxTotalSize uint64        // The -log_max_total_size flag.
+}
+
+func (r retention) isSet() bool {
+	return r.maxFiles > 0 || r.maxAge > 0 || r.maxTotalSize > 0
+}
+
+// logFile describes an existing log file.
+type logFile struct {
+	path    string
+	size    uint64
+	modTime time.Time
+	keep    bool
+}
+
+// openFiles holds the absolute names of the log files this process has open,
+// of all Loggers, with the number of times each is open.
+var openFiles = struct {
+	sync.Mutex
+	names map[string]int
+}{names: map[string]int{}}
+
+// trackFile counts the log file name as opened (delta 1) or closed (delta -1).
+func trackFile(name string, delta int) {
+	if abs, err := filepath.Abs(name); err == nil {
+		name = abs
+	}
+	openFiles.Lock()
+	defer openFiles.Unlock()
+	if openFiles.names[name] += delta; openFiles.names[name] <= 0 {
+		delete(openFiles.names, name)
+	}
+}
+
+// isOpenFile reports whether this process has the log file path open.
+func isOpenFile(path string) bool {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	openFiles.Lock()
+	defer openFiles.Unlock()
+	return openFiles.names[path] > 0
+}
+
+// removeOldFiles deletes, in the background, the oldest log files of this
+// program for tag in all log directories that exceed the retention limits.
+// The files open by any Logger of this process, the file the symlink for tag
+// points to, files that are being compressed and, with {pid} in the template,
+// the files of other running processes are kept; all count towards the limits.
+// As pids are reused, files of an exited process are kept while another process
+// has its pid.
+// l.mu is held.
+func (l *loggingT) removeOldFiles(tag string, now time.Time) {
+	if !l.retention.isSet() {
+		return
+	}
+	limits, dirs := l.retention, append([]string(nil), l.logDirs...)
+	matcher, link := l.fileTemplate.orDefault().matcher(tag), logLink(tag)
+	l.background.Add(1)
+	go func() {
+		defer l.background.Done()
+		l.retentionMu.Lock()
+		defer l.retentionMu.Unlock()
+		l.removeFiles(limits, l.oldFiles(dirs, matcher, link), now)
+	}()
+}
+
+// oldFiles returns the files in dirs that match, newest first, and whether they are kept.
+func (l *loggingT) oldFiles(dirs []string, matcher *regexp.Regexp, link string) (files []logFile) {
+	keep := map[string]bool{}
+	pidIndex := matcher.SubexpIndex("pid")
+	running := map[int]bool{pid: false} // files of this process are kept only while open
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		if dir = filepath.Clean(dir); seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		if target, err := os.Readlink(filepath.Join(dir, link)); err == nil {
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(dir, target)
+			}
+			keep[target] = true
+		}
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, each := range infos {
+			if !each.Mode().IsRegular() {
+				continue
+			}
+			match := matcher.FindStringSubmatch(each.Name())
+			if match == nil {
+				continue
+			}
+			path := filepath.Join(dir, each.Name())
+			keep[path] = keep[path] || isOpenFile(path)
+			if pidIndex > 0 {
+				if p, err := strconv.Atoi(match[pidIndex]); err == nil {
+					if _, ok := running[p]; !ok {
+						running[p] = processRunning(p)
+					}
+					keep[path] = keep[path] || running[p]
+				}
+			}
+			files = append(files, logFile{path, uint64(each.Size()), each.ModTime(), false})
+		}
+	}
+	for i := range files {
+		files[i].keep = keep[files[i].path]
+	}
+	// newest first
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].modTime.Equal(files[j].modTime) {
+			return files[i].path > files[j].path
+		}
+		return files[i].modTime.After(files[j].modTime)
+	})
+	return files
+}
+
+// removeFiles deletes the files, newest first, that exceed the limits and are
+// neither kept nor being compressed.
+func (l *loggingT) removeFiles(limits retention, files []logFile, now time.Time) {
+	var count int
+	var total uint64
+	for _, each := range files {
+		count++
+		total += each.size
+		if each.keep {
+			continue
+		}
+		if limits.maxFiles > 0 && count > limits.maxFiles ||
+			limits.maxAge > 0 && now.Sub(each.modTime) > limits.maxAge ||
+			limits.maxTotalSize > 0 && total > limits.maxTotalSize {
+			if err := l.removeUnlessCompressing(each.path); err != nil {
+				if err != errCompressing {
+					fmt.Fprintf(os.Stderr, "[glog error] unable to remove old log file: %v\n", err)
+				}
+				continue
+			}
+			count--
+			total -= each.size
+		}
+	}
+}
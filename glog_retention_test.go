@@ -0,0 +1,174 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// oldLogFiles creates INFO log files of previous runs, one per hour before now, oldest first.
+func oldLogFiles(t *testing.T, dir string, n int) []string {
+	var names []string
+	now := time.Now()
+	// as the default template with the pids of processes that no longer run
+	old, _ := parseFileTemplate("{program}.{host}.{user}.log.{tag}.{time}.9999999{seq}")
+	for i := n; i > 0; i-- {
+		name := old.format("INFO", time.Date(2006, 1, 2, 15, 4, 5, 0, time.Local), i)
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, make([]byte, 1000), 0644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := now.Add(-time.Duration(i) * time.Hour)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// infoFiles returns the names of the INFO log files in dir.
+func infoFiles(t *testing.T, dir string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, program+"."+host+"."+userName+".log.INFO.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, each := range matches {
+		names = append(names, filepath.Base(each))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func testRetention(t *testing.T, opts Options, kept int) {
+	dir := t.TempDir()
+	// files of other tests in the default temporary directory must not count
+	t.Setenv("TMPDIR", dir)
+	old := oldLogFiles(t, dir, 4)
+	opts.LogDir = dir
+	opts.StderrThreshold = "FATAL"
+	logger, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("x")
+	logger.l.background.Wait()
+	got := infoFiles(t, dir)
+	if len(got) != kept+1 {
+		t.Fatalf("got %v want the newest %d old files and the current file", got, kept)
+	}
+	// names of old files sort before the current file and newest first
+	for i, each := range old[len(old)-kept:] {
+		if got[kept-1-i] != each {
+			t.Errorf("got %v want %s kept", got, each)
+		}
+	}
+	current := logger.l.file[infoLog].(*syncBuffer).file.Name()
+	if got[kept] != filepath.Base(current) {
+		t.Errorf("current file %s removed", current)
+	}
+}
+
+// go test -v -test.run TestMaxFiles ...glog
+func TestMaxFiles(t *testing.T) {
+	testRetention(t, Options{MaxFiles: 3}, 2)
+}
+
+// go test -v -test.run TestMaxAge ...glog
+func TestMaxAge(t *testing.T) {
+	testRetention(t, Options{MaxAge: 150 * time.Minute}, 2)
+}
+
+// go test -v -test.run TestMaxTotalSize ...glog
+func TestMaxTotalSize(t *testing.T) {
+	// the current file has a header of a few hundred bytes; each old file has 1000 bytes
+	testRetention(t, Options{MaxTotalSize: 2500}, 2)
+}
+
+// go test -v -test.run TestRetentionKeepsRunningProcesses ...glog
+func TestRetentionKeepsRunningProcesses(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	old := oldLogFiles(t, dir, 2)
+	// the current file of another running process, and a file being compressed
+	running := fmt.Sprintf("%s.%s.%s.log.INFO.20060102-150405.%d", program, host, userName, os.Getppid())
+	compressing := old[1] + ".gz"
+	for _, each := range []string{running, compressing} {
+		if err := ioutil.WriteFile(filepath.Join(dir, each), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(-10 * time.Hour)
+		os.Chtimes(filepath.Join(dir, each), modTime, modTime)
+	}
+	logger, err := New(Options{LogDir: dir, MaxFiles: 1, StderrThreshold: "FATAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.l.compressing = map[string]bool{filepath.Join(dir, compressing): true}
+	logger.Info("x")
+	logger.l.background.Wait()
+	got := strings.Join(infoFiles(t, dir), " ")
+	for _, each := range old {
+		if strings.Contains(got, each+" ") {
+			t.Errorf("old file %s not removed: %s", each, got)
+		}
+	}
+	for _, each := range []string{running, compressing} {
+		if !strings.Contains(got, each) {
+			t.Errorf("file %s removed: %s", each, got)
+		}
+	}
+}
+
+// go test -v -test.run TestRetentionKeepsOpenFiles ...glog
+func TestRetentionKeepsOpenFiles(t *testing.T) {
+	dir := t.TempDir()
+	defer func(previous func() time.Time) { timeNow = previous }(timeNow)
+	now := time.Date(2006, 1, 2, 15, 4, 5, 0, time.Local)
+	timeNow = func() time.Time { return now }
+	var loggers []*Logger
+	for i := 0; i < 2; i++ {
+		logger, err := New(Options{LogDir: dir, MaxFiles: 1, RotateInterval: time.Hour, StderrThreshold: "FATAL"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer logger.Close()
+		logger.Info("x")
+		loggers = append(loggers, logger)
+	}
+	first := loggers[0].l.file[infoLog].(*syncBuffer).file.Name()
+	rotated := loggers[1].l.file[infoLog].(*syncBuffer).file.Name()
+	now = now.Add(time.Hour)
+	loggers[1].Info("rotated")
+	loggers[1].l.background.Wait()
+	if _, err := os.Stat(first); err != nil {
+		t.Errorf("open file of another Logger removed: %v", err)
+	}
+	if _, err := os.Stat(rotated); !os.IsNotExist(err) {
+		t.Errorf("rotated file %s not removed: %v", rotated, err)
+	}
+}
@@ -0,0 +1,101 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Time-based rotation of log files.
+
+package glog
+
+import (
+	"fmt"
+	"time"
+)
+
+// rotateInterval is the interval at which log files are rotated, in addition
+// to rotation by MaxSize. Zero disables time-based rotation.
+// It is the type of the -log_rotate_interval flag.
+type rotateInterval time.Duration
+
+const day = 24 * time.Hour
+
+func (r *rotateInterval) String() string {
+	switch time.Duration(*r) {
+	case 0:
+		return ""
+	case time.Hour:
+		return "hourly"
+	case day:
+		return "daily"
+	}
+	return time.Duration(*r).String()
+}
+
+// Get is part of the flag.Value interface.
+func (r *rotateInterval) Get() interface{} {
+	return time.Duration(*r)
+}
+
+// Set is part of the flag.Value interface. It accepts "hourly", "daily" or a duration such as "6h".
+func (r *rotateInterval) Set(value string) error {
+	d, err := parseRotateInterval(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	*r = rotateInterval(d)
+	return nil
+}
+
+// parseRotateInterval parses the value of -log_rotate_interval.
+func parseRotateInterval(value string) (time.Duration, error) {
+	switch value {
+	case "", "0", "never":
+		return 0, nil
+	case "hourly":
+		return time.Hour, nil
+	case "daily":
+		return day, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("log: invalid rotate interval %q: want hourly, daily or a duration", value)
+	}
+	if d < time.Second {
+		return 0, fmt.Errorf("log: rotate interval %v is shorter than a second", d)
+	}
+	return d, nil
+}
+
+// nextRotation returns the time after now at which a file created at now is
+// rotated, or the zero time if time-based rotation is disabled. Rotations are
+// aligned to multiples of the interval since local midnight, so hourly files
+// start on the hour. Intervals of whole days rotate at midnight; other
+// intervals also rotate at midnight so every day starts with a new file.
+func nextRotation(now time.Time, interval time.Duration) time.Time {
+	if interval <= 0 {
+		return time.Time{}
+	}
+	year, month, dd := now.Date()
+	midnight := time.Date(year, month, dd, 0, 0, 0, 0, now.Location())
+	if interval%day == 0 {
+		return midnight.AddDate(0, 0, int(interval/day))
+	}
+	next := midnight.Add((now.Sub(midnight)/interval + 1) * interval)
+	if tomorrow := midnight.AddDate(0, 0, 1); next.After(tomorrow) {
+		next = tomorrow
+	}
+	return next
+}
@@ -0,0 +1,87 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextRotation(t *testing.T) {
+	now := time.Date(2006, 1, 2, 15, 4, 5, 0, time.Local)
+	for _, each := range []struct {
+		interval time.Duration
+		want     time.Time
+	}{
+		{0, time.Time{}},
+		{time.Hour, time.Date(2006, 1, 2, 16, 0, 0, 0, time.Local)},
+		{15 * time.Minute, time.Date(2006, 1, 2, 15, 15, 0, 0, time.Local)},
+		{7 * time.Hour, time.Date(2006, 1, 2, 21, 0, 0, 0, time.Local)},
+		{10 * time.Hour, time.Date(2006, 1, 2, 20, 0, 0, 0, time.Local)},
+		{16 * time.Hour, time.Date(2006, 1, 2, 16, 0, 0, 0, time.Local)},
+		{13 * time.Hour, time.Date(2006, 1, 3, 0, 0, 0, 0, time.Local)},
+		{day, time.Date(2006, 1, 3, 0, 0, 0, 0, time.Local)},
+		{7 * day, time.Date(2006, 1, 9, 0, 0, 0, 0, time.Local)},
+	} {
+		if got := nextRotation(now, each.interval); !got.Equal(each.want) {
+			t.Errorf("%v: got %v want %v", each.interval, got, each.want)
+		}
+	}
+}
+
+func TestParseRotateInterval(t *testing.T) {
+	for value, want := range map[string]time.Duration{"": 0, "hourly": time.Hour, "daily": day, "30m": 30 * time.Minute} {
+		if got, err := parseRotateInterval(value); err != nil || got != want {
+			t.Errorf("%q: got %v, %v want %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"weekly", "10ms", "-1h"} {
+		if _, err := parseRotateInterval(value); err == nil {
+			t.Errorf("%q: expected error", value)
+		}
+	}
+}
+
+// go test -v -test.run TestRotateInterval ...glog
+func TestRotateInterval(t *testing.T) {
+	dir := t.TempDir()
+	defer func(previous func() time.Time) { timeNow = previous }(timeNow)
+	now := time.Date(2006, 1, 2, 15, 4, 5, 0, time.Local)
+	timeNow = func() time.Time { return now }
+
+	logger, err := New(Options{LogDir: dir, RotateInterval: time.Hour, StderrThreshold: "FATAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("before")
+	info := logger.l.file[infoLog].(*syncBuffer)
+	fname0 := info.file.Name()
+	now = now.Add(30 * time.Minute)
+	logger.Info("same hour")
+	if got := info.file.Name(); got != fname0 {
+		t.Errorf("rotated within the hour: %v", got)
+	}
+	now = now.Add(30 * time.Minute)
+	logger.Info("next hour")
+	if got := info.file.Name(); got == fname0 {
+		t.Errorf("not rotated at the hour: %v", got)
+	}
+	if want := time.Date(2006, 1, 2, 17, 0, 0, 0, time.Local); !info.rotateAt.Equal(want) {
+		t.Errorf("got rotateAt %v want %v", info.rotateAt, want)
+	}
+}
@@ -0,0 +1,225 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"os"
+	"time"
+)
+
+// Record is a decoded log event as it is handed to each Sink.
+type Record struct {
+	Time     time.Time // When the event was logged.
+	Severity string    // "TRACE", "DEBUG", "INFO", "WARNING", "ERROR" or "FATAL".
+	ThreadID int       // The value of the threadid column in the header.
+	File     string    // Base name of the source file of the logging call, or another form; see -log_file_path.
+	Line     int       // Line number of the logging call.
+	Message  string    // The user-supplied message, without the header and trailing newline.
+	Fields   []Field   // Key/value pairs bound by With and passed to functions such as Infow; read-only.
+	Data     []byte    // The formatted log line(s); only valid during Emit.
+	Stack    []byte    // Stack trace of all goroutines for FATAL, nil otherwise.
+
+	sev   severity // Internal representation of Severity.
+	hlen  int      // Length of the header in Data.
+	base  string   // Base name of the source file, for -log_backtrace_at.
+	trace []byte   // Stack trace of -log_backtrace_at, also at the end of Data.
+
+	encoded   *buffer // Data in another format than text; see loggingT.data.
+	encodedAs format
+}
+
+// stack returns the stack trace of a FATAL record or of -log_backtrace_at, if any.
+func (r *Record) stack() []byte {
+	if r.Stack != nil {
+		return r.Stack
+	}
+	return r.trace
+}
+
+// Sink is a destination for log records. The built-in destinations (stderr,
+// the log files and the logstash publisher) are sinks too.
+// All methods are called with the logging lock held, so a Sink must not log itself.
+type Sink interface {
+	// Emit receives a record.
+	Emit(r *Record) error
+	// Flush writes any buffered data of the sink.
+	Flush() error
+	// Close releases the resources of the sink; it is not used afterwards.
+	Close() error
+}
+
+// asyncSink is implemented by sinks that write in the background, such as the
+// logstash publisher. Their Flush only starts writing, so it does not hold
+// up logging; wait waits at most timeout until the records are written.
+type asyncSink interface {
+	Sink
+	wait(timeout time.Duration)
+}
+
+// asyncFlushTimeout is how long Flush and a fatal record wait for each asyncSink.
+const asyncFlushTimeout = 2 * time.Second
+
+// AddSink registers a Sink with the default Logger that receives all records
+// after those already registered.
+func AddSink(s Sink) {
+	logging.addSink(s)
+}
+
+// RemoveSink unregisters a Sink previously added to the default Logger,
+// then flushes and closes it. It returns false if the sink was not registered.
+func RemoveSink(s Sink) bool {
+	return logging.removeSink(s)
+}
+
+func (l *loggingT) addSink(s Sink) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sinks = append(l.sinks, s)
+}
+
+func (l *loggingT) removeSink(s Sink) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, each := range l.sinks {
+		if each == s {
+			l.sinks = append(l.sinks[:i:i], l.sinks[i+1:]...)
+			s.Flush() // ignore error
+			s.Close() // ignore error
+			return true
+		}
+	}
+	return false
+}
+
+// stderrSink writes the formatted data to standard error as specified
+// by the -logtostderr, -alsologtostderr and -stderrthreshold flags.
+type stderrSink struct {
+	logger *loggingT
+}
+
+func (s *stderrSink) Emit(r *Record) error {
+	l := s.logger
+	data := l.data(r, l.format.stderr)
+	if l.toStderr {
+		_, err := os.Stderr.Write(data)
+		return err
+	}
+	if l.alsoToStderr || r.sev >= l.stderrThreshold.get() {
+		os.Stderr.Write(data)
+	}
+	if r.sev == fatalLog && l.format.stderr == textFormat {
+		// Make sure we see the trace for the current goroutine on standard error.
+		os.Stderr.Write(stacks(false))
+	}
+	return nil
+}
+
+func (s *stderrSink) Flush() error { return nil }
+
+func (s *stderrSink) Close() error { return nil }
+
+// fileSink writes the formatted data to the log file of its severity and to
+// those of all lower severities, unless -logtostderr is set.
+// See -log_debug_files for the files of DEBUG and TRACE and -log_files
+// for other mappings of severities to files.
+type fileSink struct {
+	logger *loggingT
+}
+
+func (f *fileSink) Emit(r *Record) error {
+	l := f.logger
+	if l.toStderr {
+		return nil
+	}
+	var files [numSeverity]severity
+	targets := l.appendFileTargets(files[:0], r.sev)
+	data := l.data(r, l.format.file)
+	if err := l.createFiles(targets); err != nil {
+		l.fileError(err, data)
+		return nil
+	}
+	var failure error
+	for _, log := range targets {
+		if _, err := l.file[log].Write(data); err != nil && failure == nil {
+			failure = err
+		}
+	}
+	if failure != nil {
+		l.fileError(failure, data)
+	}
+	if d := l.durability.severity[r.sev]; d != buffered {
+		for _, log := range targets {
+			l.file[log].Flush() // ignore error
+			if d == synced {
+				l.file[log].Sync() // ignore error
+			}
+		}
+	}
+	if r.Stack != nil && l.format.file == textFormat {
+		for log := fatalLog; log >= traceLog; log-- {
+			if f := l.file[log]; f != nil {
+				f.Write(r.Stack)
+			}
+		}
+	}
+	return nil
+}
+
+// Flush flushes the files from fatal down, in case there's trouble flushing,
+// and attempts to "sync" their data to disk.
+func (f *fileSink) Flush() error {
+	l := f.logger
+	for s := fatalLog; s >= traceLog; s-- {
+		file := l.file[s]
+		if file != nil {
+			file.Flush() // ignore error
+			file.Sync()  // ignore error
+		}
+	}
+	return nil
+}
+
+// Close closes all log files; new ones are created by the next Emit.
+func (f *fileSink) Close() error {
+	l := f.logger
+	for s := fatalLog; s >= traceLog; s-- {
+		if sb, ok := l.file[s].(*syncBuffer); ok && sb.file != nil {
+			sb.Flush()
+			sb.closeFile()
+		}
+		l.file[s] = nil
+	}
+	return nil
+}
+
+// waitSinks waits for each asyncSink of l at most timeout.
+// l.mu is not held, so logging goes on meanwhile.
+func (l *loggingT) waitSinks(timeout time.Duration) {
+	l.mu.Lock()
+	sinks := l.sinks
+	l.mu.Unlock()
+	waitAsync(sinks, timeout)
+}
+
+// waitAsync waits for each asyncSink of sinks at most timeout.
+func waitAsync(sinks []Sink, timeout time.Duration) {
+	for _, each := range sinks {
+		if s, ok := each.(asyncSink); ok {
+			s.wait(timeout)
+		}
+	}
+}
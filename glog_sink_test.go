@@ -0,0 +1,68 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"testing"
+)
+
+// captureSink collects all records it receives.
+type captureSink struct {
+	records []Record
+	closed  bool
+}
+
+func (c *captureSink) Emit(r *Record) error {
+	c.records = append(c.records, *r)
+	return nil
+}
+
+func (c *captureSink) Flush() error { return nil }
+
+func (c *captureSink) Close() error {
+	c.closed = true
+	return nil
+}
+
+// go test -v -test.run TestAddRemoveSink ...glog
+func TestAddRemoveSink(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	sink := new(captureSink)
+	AddSink(sink)
+	Warning("hello sink")
+	if !RemoveSink(sink) {
+		t.Fatal("sink was not registered")
+	}
+	Info("not captured")
+	if got, want := len(sink.records), 1; got != want {
+		t.Fatalf("got %d records, want %d", got, want)
+	}
+	r := sink.records[0]
+	if r.Severity != "WARNING" || r.Message != "hello sink" || r.File != "glog_sink_test.go" {
+		t.Errorf("unexpected record: %+v", r)
+	}
+	if !sink.closed {
+		t.Error("sink was not closed on removal")
+	}
+	if !contains(warningLog, "hello sink", t) {
+		t.Error("built-in file sink did not receive the record")
+	}
+	if RemoveSink(sink) {
+		t.Error("sink removed twice")
+	}
+}
@@ -0,0 +1,223 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// spoolSuffix is the extension of spool segment files.
+const spoolSuffix = ".spool"
+
+// spool stores logstash events in segment files while the writer is failing.
+// Each event is stored as a 4-byte big-endian length followed by its data.
+// Segments are replayed and deleted oldest first. It is only used by the
+// goroutine of an asyncWriter.
+type spool struct {
+	dir         string
+	maxSize     int64 // Maximum total size of all segments.
+	segmentSize int64 // A new segment is started when the last one reaches this size.
+	segments    []*spoolSegment
+	size        int64 // Total size of all segments.
+	nextSeq     int
+}
+
+// spoolSegment describes one segment file.
+type spoolSegment struct {
+	name   string
+	size   int64
+	events int
+}
+
+// openSpool creates the spool directory if needed and loads the segments left by a previous run.
+func openSpool(dir string, maxSize, segmentSize int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	s := &spool{dir: dir, maxSize: maxSize, segmentSize: segmentSize}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []int
+	for _, each := range infos {
+		name := each.Name()
+		if !strings.HasSuffix(name, spoolSuffix) {
+			continue
+		}
+		seq, err := strconv.Atoi(strings.TrimSuffix(name, spoolSuffix))
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	for _, seq := range seqs {
+		segment := &spoolSegment{name: filepath.Join(dir, segmentName(seq))}
+		events, err := segment.read()
+		if err != nil {
+			return nil, err
+		}
+		for _, each := range events {
+			segment.size += int64(4 + len(each))
+		}
+		segment.events = len(events)
+		s.segments = append(s.segments, segment)
+		s.size += segment.size
+		s.nextSeq = seq + 1
+	}
+	return s, nil
+}
+
+func segmentName(seq int) string {
+	return fmt.Sprintf("%010d%s", seq, spoolSuffix)
+}
+
+// pending reports whether there are spooled events.
+func (s *spool) pending() bool {
+	return len(s.segments) > 0
+}
+
+// append stores the events after those already spooled, evicting the oldest
+// segments if the spool becomes larger than its maximum size. It returns the
+// number of events stored, which is less than len(events) on error.
+func (s *spool) append(events [][]byte) (stored int, err error) {
+	for len(events) > 0 {
+		if len(s.segments) == 0 || s.segments[len(s.segments)-1].size >= s.segmentSize {
+			s.segments = append(s.segments, &spoolSegment{name: filepath.Join(s.dir, segmentName(s.nextSeq))})
+			s.nextSeq++
+		}
+		last := s.segments[len(s.segments)-1]
+		n, err := last.append(events, s.segmentSize)
+		atomic.AddInt64(&LogstashStats.spooled, int64(n))
+		stored += n
+		s.recount()
+		if err != nil {
+			return stored, err
+		}
+		events = events[n:]
+	}
+	for s.size > s.maxSize && len(s.segments) > 1 {
+		s.evict()
+	}
+	return stored, nil
+}
+
+// recount recomputes the total size from the segments.
+func (s *spool) recount() {
+	s.size = 0
+	for _, each := range s.segments {
+		s.size += each.size
+	}
+}
+
+// evict deletes the oldest segment.
+func (s *spool) evict() {
+	oldest := s.segments[0]
+	os.Remove(oldest.name) // ignore error
+	s.segments = s.segments[1:]
+	s.size -= oldest.size
+	atomic.AddInt64(&LogstashStats.evicted, int64(oldest.events))
+}
+
+// replay writes the spooled events oldest first with write and deletes each
+// segment that was written. It stops at the first error.
+func (s *spool) replay(write func(events [][]byte) error) error {
+	for len(s.segments) > 0 {
+		oldest := s.segments[0]
+		events, err := oldest.read()
+		if err != nil {
+			// Unreadable; nothing to replay from it.
+			fmt.Fprintf(os.Stderr, "[glog error] unable to read logstash spool segment: %v\n", err)
+			s.evict()
+			continue
+		}
+		if len(events) > 0 {
+			if err := write(events); err != nil {
+				return err
+			}
+		}
+		os.Remove(oldest.name) // ignore error
+		s.segments = s.segments[1:]
+		s.size -= oldest.size
+		atomic.AddInt64(&LogstashStats.replayed, int64(len(events)))
+	}
+	return nil
+}
+
+// append writes events to the segment until it reaches maxSize, but at least one.
+// It returns the number of events written.
+func (g *spoolSegment) append(events [][]byte, maxSize int64) (int, error) {
+	f, err := os.OpenFile(g.name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	var data []byte
+	var ends []int // the end of each event in data
+	var length [4]byte
+	for _, each := range events {
+		if len(ends) > 0 && g.size+int64(len(data)) >= maxSize {
+			break
+		}
+		binary.BigEndian.PutUint32(length[:], uint32(len(each)))
+		data = append(append(data, length[:]...), each...)
+		ends = append(ends, len(data))
+	}
+	written, err := f.Write(data)
+	n, stored := 0, 0
+	for n < len(ends) && ends[n] <= written {
+		stored = ends[n]
+		n++
+	}
+	if stored < written {
+		// Remove the incomplete event, so that later events can be read.
+		f.Truncate(g.size + int64(stored)) // ignore error
+	}
+	g.size += int64(stored)
+	g.events += n
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// read returns all events stored in the segment. An incomplete last event,
+// left by a process that stopped while spooling, is ignored.
+func (g *spoolSegment) read() ([][]byte, error) {
+	data, err := ioutil.ReadFile(g.name)
+	if err != nil {
+		return nil, err
+	}
+	var events [][]byte
+	for len(data) >= 4 {
+		n := int(binary.BigEndian.Uint32(data))
+		if 4+n > len(data) {
+			break
+		}
+		events = append(events, data[4:4+n])
+		data = data[4+n:]
+	}
+	return events, nil
+}
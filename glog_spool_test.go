@@ -0,0 +1,162 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// toggleWriter fails all writes until it is enabled.
+type toggleWriter struct {
+	mu      sync.Mutex
+	enabled bool
+	written bytes.Buffer
+}
+
+func (w *toggleWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.enabled {
+		return 0, errors.New("simulated fail")
+	}
+	return w.written.Write(p)
+}
+
+func (w *toggleWriter) enable() {
+	w.mu.Lock()
+	w.enabled = true
+	w.mu.Unlock()
+}
+
+func (w *toggleWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.written.String()
+}
+
+// go test -v -test.run TestLogstashSpoolReplay ...glog
+func TestLogstashSpoolReplay(t *testing.T) {
+	dir := t.TempDir()
+	w := new(toggleWriter)
+	a := newAsyncWriter(w, LogstashOptions{BatchSize: 1, SpoolDir: dir}.withDefaults())
+	spooled, replayed, failed := LogstashStats.Spooled(), LogstashStats.Replayed(), LogstashStats.Failed()
+	a.Write([]byte("a"))
+	a.Write([]byte("b"))
+	a.flush(time.Minute)
+	w.enable()
+	a.Write([]byte("c"))
+	a.close()
+	if got := w.String(); got != "abc" {
+		t.Errorf("got %q want %q", got, "abc")
+	}
+	if got := LogstashStats.Spooled() - spooled; got != 2 {
+		t.Errorf("got %d spooled events want 2", got)
+	}
+	if got := LogstashStats.Replayed() - replayed; got != 2 {
+		t.Errorf("got %d replayed events want 2", got)
+	}
+	if got := LogstashStats.Failed() - failed; got != 0 {
+		t.Errorf("got %d failed events want 0", got)
+	}
+	if names, _ := ioutil.ReadDir(dir); len(names) != 0 {
+		t.Errorf("got %d segments after replay want 0", len(names))
+	}
+}
+
+// go test -v -test.run TestSpoolEvict ...glog
+func TestSpoolEvict(t *testing.T) {
+	dir := t.TempDir()
+	// each event takes 5 bytes; two per segment, at most two segments
+	s, err := openSpool(dir, 20, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evicted := LogstashStats.Evicted()
+	for _, each := range []string{"a", "b", "c", "d", "e"} {
+		if _, err := s.append([][]byte{[]byte(each)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := LogstashStats.Evicted() - evicted; got != 2 {
+		t.Errorf("got %d evicted events want 2", got)
+	}
+	// reopen to load the segments left on disk
+	s, err = openSpool(dir, 20, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	s.replay(func(events [][]byte) error {
+		got = append(got, bytes.Join(events, nil)...)
+		return nil
+	})
+	if string(got) != "cde" {
+		t.Errorf("got %q want %q", got, "cde")
+	}
+	if s.pending() {
+		t.Error("spool not empty after replay")
+	}
+}
+
+// go test -v -test.run TestSpoolPartialAppend ...glog
+func TestSpoolPartialAppend(t *testing.T) {
+	dir := t.TempDir()
+	// each event takes 5 bytes, one per segment
+	s, err := openSpool(dir, 100, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the second segment cannot be created
+	if err := os.Mkdir(filepath.Join(dir, segmentName(1)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.append([][]byte{[]byte("a"), []byte("b")})
+	if n != 1 || err == nil {
+		t.Errorf("got %d stored events and error %v want 1 and an error", n, err)
+	}
+}
+
+// go test -v -test.run TestLogstashSpoolFlag ...glog
+func TestLogstashSpoolFlag(t *testing.T) {
+	w := new(toggleWriter)
+	defer func(spool bool, dir string) { logstash.spool, logging.logDir = spool, dir }(logstash.spool, logging.logDir)
+	logstash.spool, logging.logDir = true, t.TempDir()
+	SetLogstashWriterOptions(w, LogstashOptions{BatchSize: 1})
+	defer SetLogstashWriter(os.Stderr)
+	a := logstash.writer
+	if want := filepath.Join(logging.logDir, program+".logstash.spool"); a.opts.SpoolDir != want {
+		t.Errorf("got spool directory %q want %q", a.opts.SpoolDir, want)
+	}
+	spooled := LogstashStats.Spooled()
+	a.Write([]byte("a"))
+	a.flush(time.Minute)
+	if got := LogstashStats.Spooled() - spooled; got != 1 {
+		t.Errorf("got %d spooled events want 1", got)
+	}
+	w.enable()
+	a.flush(time.Minute)
+	if got := w.String(); got != "a" {
+		t.Errorf("got %q want %q", got, "a")
+	}
+}
@@ -0,0 +1,267 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Templates for the names of log files.
+
+package glog
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultFileTemplate produces the names of the C++ glog library:
+// program.host.user.log.TAG.YYYYMMDD-HHMMSS.pid
+const defaultFileTemplate = "{program}.{host}.{user}.log.{tag}.{time}.{pid}"
+
+// defaultTimeLayout is the layout of {time} without a layout of its own.
+const defaultTimeLayout = "20060102-150405"
+
+// templatePart is a literal or, if name is set, a placeholder of a fileTemplate.
+type templatePart struct {
+	literal string
+	name    string // program, host, user, pid, tag, time or seq
+	layout  string // of time
+}
+
+// fileTemplate is a parsed log file name template. The zero value uses defaultFileTemplate.
+// It is the type of the -log_file_template flag.
+type fileTemplate struct {
+	text  string
+	parts []templatePart
+}
+
+var errTemplateSyntax = errors.New("syntax error: expect {placeholder} with program, host, user, pid, tag, severity, date, time, time:layout or seq")
+
+func (f *fileTemplate) String() string {
+	return f.text
+}
+
+// Get is part of the flag.Value interface.
+func (f *fileTemplate) Get() interface{} {
+	return f.text
+}
+
+// Set is part of the flag.Value interface.
+func (f *fileTemplate) Set(value string) error {
+	parsed, err := parseFileTemplate(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	*f = parsed
+	return nil
+}
+
+// parseFileTemplate parses a template such as "{program}-{tag}-{date}.log".
+// The empty string is the default template. A template without {tag} or
+// {severity} gets ".{tag}" appended, so the files of severities differ.
+func parseFileTemplate(text string) (fileTemplate, error) {
+	if text == "" {
+		text = defaultFileTemplate
+	}
+	if strings.ContainsAny(text, `/\`) {
+		return fileTemplate{}, fmt.Errorf("log: file template %q must not contain a path separator", text)
+	}
+	t := fileTemplate{text: text}
+	for rest := text; rest != ""; {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			t.parts = append(t.parts, templatePart{literal: rest})
+			break
+		}
+		if rest[open] == '}' {
+			return fileTemplate{}, errTemplateSyntax
+		}
+		if open > 0 {
+			t.parts = append(t.parts, templatePart{literal: rest[:open]})
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return fileTemplate{}, errTemplateSyntax
+		}
+		part, err := parsePlaceholder(rest[open+1 : open+end])
+		if err != nil {
+			return fileTemplate{}, err
+		}
+		t.parts = append(t.parts, part)
+		rest = rest[open+end+1:]
+	}
+	if !t.has("tag") {
+		t.parts = append(t.parts, templatePart{literal: "."}, templatePart{name: "tag"})
+	}
+	return t, nil
+}
+
+// parsePlaceholder parses the text between braces.
+func parsePlaceholder(text string) (templatePart, error) {
+	switch text {
+	case "program", "host", "user", "pid", "tag", "seq":
+		return templatePart{name: text}, nil
+	case "severity":
+		return templatePart{name: "tag"}, nil
+	case "date":
+		return templatePart{name: "time", layout: "20060102"}, nil
+	case "time":
+		return templatePart{name: "time", layout: defaultTimeLayout}, nil
+	}
+	if strings.HasPrefix(text, "time:") && len(text) > len("time:") {
+		layout := text[len("time:"):]
+		if strings.ContainsAny(layout, `/\`) {
+			return templatePart{}, fmt.Errorf("log: time layout %q must not contain a path separator", layout)
+		}
+		return templatePart{name: "time", layout: layout}, nil
+	}
+	return templatePart{}, errTemplateSyntax
+}
+
+// orDefault returns the template, or the default template if it is the zero value.
+func (f fileTemplate) orDefault() fileTemplate {
+	if f.parts == nil {
+		f, _ = parseFileTemplate(defaultFileTemplate)
+	}
+	return f
+}
+
+// hasSeq reports whether the template contains {seq}.
+func (f fileTemplate) hasSeq() bool {
+	return f.has("seq")
+}
+
+// has reports whether the template contains the placeholder name.
+func (f fileTemplate) has(name string) bool {
+	for _, each := range f.parts {
+		if each.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// appends reports whether a new file for an existing name appends to it,
+// which is the case without {seq} and without a time with seconds.
+func (f fileTemplate) appends() bool {
+	for _, each := range f.parts {
+		if each.name == "seq" || each.name == "time" && strings.Contains(each.layout, "05") {
+			return false
+		}
+	}
+	return true
+}
+
+// format returns the file name for tag, start time t and sequence number seq.
+func (f fileTemplate) format(tag string, t time.Time, seq int) string {
+	var b strings.Builder
+	for _, each := range f.parts {
+		switch each.name {
+		case "":
+			b.WriteString(each.literal)
+		case "program":
+			b.WriteString(program)
+		case "host":
+			b.WriteString(host)
+		case "user":
+			b.WriteString(userName)
+		case "pid":
+			b.WriteString(strconv.Itoa(pid))
+		case "tag":
+			b.WriteString(tag)
+		case "time":
+			b.WriteString(t.Format(each.layout))
+		case "seq":
+			b.WriteString(strconv.Itoa(seq))
+		}
+	}
+	return b.String()
+}
+
+// name returns the name for a new file for tag in dir and whether to append to it.
+// With {seq}, it is the lowest number for which no file, nor its compressed form
+// with suffix, exists. Without {seq}, a name that exists gets the lowest such
+// number as another extension, such as .1, unless the template appends. An
+// appending template appends to the file, unless it is the file being rotated,
+// such as on reaching MaxSize, or its compressed form exists.
+func (f fileTemplate) name(dir, tag string, t time.Time, suffix, rotated string) (string, bool) {
+	if f.appends() {
+		name := f.format(tag, t, 0)
+		if filepath.Join(dir, name) != rotated && (suffix == "" || !exists(filepath.Join(dir, name+suffix))) {
+			return name, true
+		}
+	}
+	seq, base := 0, ""
+	if !f.hasSeq() {
+		base = f.format(tag, t, 0)
+	}
+	for ; ; seq++ {
+		name := base
+		if base == "" {
+			name = f.format(tag, t, seq)
+		} else if seq > 0 {
+			name = base + "." + strconv.Itoa(seq)
+		}
+		if exists(filepath.Join(dir, name)) || suffix != "" && exists(filepath.Join(dir, name+suffix)) {
+			continue
+		}
+		return name, false
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return !os.IsNotExist(err)
+}
+
+// matcher returns a regular expression matching the names of all files of this
+// program for tag, with any pid, time and sequence number and an optional
+// suffix such as ".gz". The pid is the subexpression named pid.
+func (f fileTemplate) matcher(tag string) *regexp.Regexp {
+	seq, pid := "", `(?P<pid>\d+)` // a named group for the first {pid}
+	if !f.hasSeq() {
+		seq = `(\.\d+)?`
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	for _, each := range f.parts {
+		switch each.name {
+		case "":
+			b.WriteString(regexp.QuoteMeta(each.literal))
+		case "program":
+			b.WriteString(regexp.QuoteMeta(program))
+		case "host":
+			b.WriteString(regexp.QuoteMeta(host))
+		case "user":
+			b.WriteString(regexp.QuoteMeta(userName))
+		case "tag":
+			b.WriteString(regexp.QuoteMeta(tag))
+		case "pid":
+			b.WriteString(pid)
+			pid = `\d+`
+		case "seq":
+			b.WriteString(`\d+`)
+		case "time":
+			b.WriteString(`.+?`)
+		}
+	}
+	b.WriteString(seq + `(\.[a-z0-9]+)?$`)
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,169 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileTemplateFormat(t *testing.T) {
+	now := time.Date(2006, 1, 2, 15, 4, 5, 0, time.Local)
+	for text, want := range map[string]string{
+		"":                                   fmt.Sprintf("%s.%s.%s.log.INFO.20060102-150405.%d", program, host, userName, pid),
+		"{program}-{severity}-{date}.log":    program + "-INFO-20060102.log",
+		"app.{tag}.{time:2006-01-02T15}.log": "app.INFO.2006-01-02T15.log",
+		"{tag}.{seq}":                        "INFO.7",
+		"{program}.log":                      program + ".log.INFO",
+	} {
+		f, err := parseFileTemplate(text)
+		if err != nil {
+			t.Fatalf("%q: %v", text, err)
+		}
+		if got := f.format("INFO", now, 7); got != want {
+			t.Errorf("%q: got %q want %q", text, got, want)
+		}
+		if !f.matcher("INFO").MatchString(want+".gz") || f.matcher("WARNING").MatchString(want) {
+			t.Errorf("%q: matcher %v", text, f.matcher("INFO"))
+		}
+	}
+}
+
+func TestFileTemplateSyntax(t *testing.T) {
+	for _, text := range []string{"{program", "program}", "{prog}", "{time:}", "logs/{tag}", "{time:2006/01/02}"} {
+		if _, err := parseFileTemplate(text); err == nil {
+			t.Errorf("%q: expected error", text)
+		}
+	}
+}
+
+// go test -v -test.run TestFileTemplateSeq ...glog
+func TestFileTemplateSeq(t *testing.T) {
+	dir := t.TempDir()
+	// the file of a previous run and its rotated, compressed successor
+	for _, each := range []string{"INFO.0.log", "INFO.1.log.gz"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, each), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logger, err := New(Options{LogDir: dir, FileTemplate: "{tag}.{seq}.log", Compress: true, StderrThreshold: "FATAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("x")
+	if got, want := logger.l.file[infoLog].(*syncBuffer).file.Name(), filepath.Join(dir, "INFO.2.log"); got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	if _, err := New(Options{FileTemplate: "{nope}"}); err == nil {
+		t.Error("expected error for invalid template")
+	}
+}
+
+// go test -v -test.run TestDefaultNameUnique ...glog
+func TestDefaultNameUnique(t *testing.T) {
+	defer func(previous func() time.Time) { timeNow = previous }(timeNow)
+	now := time.Date(2006, 1, 2, 15, 4, 5, 0, time.Local)
+	timeNow = func() time.Time { return now }
+	dir := t.TempDir()
+	var names []string
+	for _, msg := range []string{"first", "second"} {
+		logger, err := New(Options{LogDir: dir, StderrThreshold: "FATAL"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer logger.Close()
+		logger.Info(msg)
+		logger.Flush()
+		names = append(names, logger.l.file[infoLog].(*syncBuffer).file.Name())
+	}
+	if names[1] != names[0]+".1" {
+		t.Fatalf("got %q and %q want a .1 extension", names[0], names[1])
+	}
+	if got := readFile(t, names[0]); !strings.Contains(got, "] first\n") || strings.Contains(got, "second") {
+		t.Errorf("unexpected first file: %q", got)
+	}
+	if !logging.fileTemplate.orDefault().matcher("INFO").MatchString(filepath.Base(names[1]) + ".gz") {
+		t.Errorf("matcher does not match %q", names[1])
+	}
+}
+
+func TestFileTemplateAppends(t *testing.T) {
+	for text, want := range map[string]bool{
+		"":                                   false,
+		"{program}-{severity}-{date}.log":    true,
+		"app.{tag}.{time:2006-01-02T15}.log": true,
+		"{tag}.{time}.log":                   false,
+		"{tag}.{seq}":                        false,
+	} {
+		f, _ := parseFileTemplate(text)
+		if got := f.appends(); got != want {
+			t.Errorf("%q: got %v want %v", text, got, want)
+		}
+	}
+}
+
+// go test -v -test.run TestAppendingTemplateMaxSize ...glog
+func TestAppendingTemplateMaxSize(t *testing.T) {
+	defer func(previous uint64) { MaxSize = previous }(MaxSize)
+	MaxSize = 512
+	dir := t.TempDir()
+	// the file of a previous run
+	if err := ioutil.WriteFile(filepath.Join(dir, "INFO.log"), []byte(strings.Repeat("x", 400)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	logger, err := New(Options{LogDir: dir, FileTemplate: "{tag}.log", StderrThreshold: "FATAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logger.Close()
+	logger.Info("appended")
+	info := logger.l.file[infoLog].(*syncBuffer)
+	if got, want := info.file.Name(), filepath.Join(dir, "INFO.log"); got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	logger.Info(strings.Repeat("y", 200)) // beyond MaxSize
+	logger.Flush()
+	if got, want := info.file.Name(), filepath.Join(dir, "INFO.log.1"); got != want {
+		t.Errorf("got %q want %q after reaching MaxSize", got, want)
+	}
+	if got := readFile(t, filepath.Join(dir, "INFO.log")); strings.Contains(got, "Log file created at") || !strings.Contains(got, "] appended\n") {
+		t.Errorf("unexpected appended file: %q", got)
+	}
+	if got := readFile(t, filepath.Join(dir, "INFO.log.1")); !strings.HasPrefix(got, "Log file created at") || !strings.Contains(got, "yyy") {
+		t.Errorf("unexpected new file: %q", got)
+	}
+}
+
+func TestAppendingTemplateCompressed(t *testing.T) {
+	dir := t.TempDir()
+	f, _ := parseFileTemplate("{tag}.log")
+	if name, appends := f.name(dir, "INFO", time.Now(), ".gz", ""); name != "INFO.log" || !appends {
+		t.Errorf("got %q, %v want INFO.log appending", name, appends)
+	}
+	// a compressed file of a previous run
+	if err := ioutil.WriteFile(filepath.Join(dir, "INFO.log.gz"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if name, appends := f.name(dir, "INFO", time.Now(), ".gz", ""); name != "INFO.log.1" || appends {
+		t.Errorf("got %q, %v want a new INFO.log.1", name, appends)
+	}
+}
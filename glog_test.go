@@ -65,7 +65,11 @@ func (l *loggingT) swap(writers [numSeverity]flushSyncWriter) (old [numSeverity]
 
 // newBuffers sets the log writers to all new byte buffers and returns the old array.
 func (l *loggingT) newBuffers() [numSeverity]flushSyncWriter {
-	return l.swap([numSeverity]flushSyncWriter{new(flushBuffer), new(flushBuffer), new(flushBuffer), new(flushBuffer)})
+	var writers [numSeverity]flushSyncWriter
+	for i := range writers {
+		writers[i] = new(flushBuffer)
+	}
+	return l.swap(writers)
 }
 
 // contents returns the specified log value as a string.
@@ -112,6 +116,42 @@ func TestHeader(t *testing.T) {
 	}
 }
 
+// depthHelper logs on behalf of its caller.
+func depthHelper(msg string) {
+	InfoDepth(1, msg)
+}
+
+// Test that InfoDepth reports the call site of the helper's caller.
+func TestInfoDepth(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	_, _, line, _ := runtime.Caller(0)
+	depthHelper("depth")
+	want := fmt.Sprintf("glog_test.go:%d] depth\n", line+1)
+	if !contains(infoLog, want, t) {
+		t.Errorf("missing %q in %q", want, contents(infoLog))
+	}
+}
+
+// Test that VDepth looks up the vmodule level of the helper's caller.
+func TestVDepth(t *testing.T) {
+	setFlags()
+	defer logging.swap(logging.newBuffers())
+	logging.vmodule.Set("glog_test=2")
+	defer logging.vmodule.Set("")
+	enabled := func(depth int) Verbose { return VDepth(depth, 2) }
+	if !enabled(1) {
+		t.Error("VDepth not enabled for caller in glog_test")
+	}
+	logging.vmodule.Set("testing=2")
+	if enabled(1) {
+		t.Error("VDepth enabled for caller in glog_test")
+	}
+	if !enabled(2) {
+		t.Error("VDepth not enabled for caller in testing")
+	}
+}
+
 // Test that an Error log goes to Warning and Info.
 // Even in the Info log, the source character will be E, so the data should
 // all be identical.
@@ -328,6 +368,16 @@ func TestLogBacktraceAt(t *testing.T) {
 
 func BenchmarkHeader(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		logging.putBuffer(logging.header(infoLog))
+		buf, _ := logging.header(infoLog, 0)
+		logging.putBuffer(buf)
+	}
+}
+
+func BenchmarkHeaderGoroutineID(b *testing.B) {
+	defer func(previous bool) { logging.goroutineID = previous }(logging.goroutineID)
+	logging.goroutineID = true
+	for i := 0; i < b.N; i++ {
+		buf, _ := logging.header(infoLog, 0)
+		logging.putBuffer(buf)
 	}
 }
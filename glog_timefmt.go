@@ -0,0 +1,132 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Formats of the time in the header of log lines.
+
+package glog
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeFormat is the format of the time in the header.
+// It is the type of the -log_time_format flag.
+type timeFormat int8
+
+const (
+	shortTime   timeFormat = iota // mmdd hh:mm:ss.uuuuuu, as the C++ library.
+	yearTime                      // yyyymmdd hh:mm:ss.uuuuuu
+	rfc3339Time                   // yyyy-mm-ddThh:mm:ss.uuuuuu+hh:mm
+)
+
+var timeFormatName = []string{
+	shortTime:   "default",
+	yearTime:    "year",
+	rfc3339Time: "rfc3339",
+}
+
+func (f *timeFormat) String() string {
+	if *f < 0 || int(*f) >= len(timeFormatName) {
+		return fmt.Sprintf("timeFormat(%d)", int8(*f))
+	}
+	return timeFormatName[*f]
+}
+
+// Get is part of the flag.Value interface.
+func (f *timeFormat) Get() interface{} {
+	return *f
+}
+
+// Set is part of the flag.Value interface.
+func (f *timeFormat) Set(value string) error {
+	parsed, err := parseTimeFormat(value)
+	if err != nil {
+		return err
+	}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	*f = parsed
+	return nil
+}
+
+// parseTimeFormat parses "default", "year" or "rfc3339". The empty string is the default.
+func parseTimeFormat(value string) (timeFormat, error) {
+	if value == "" {
+		return shortTime, nil
+	}
+	for i, name := range timeFormatName {
+		if strings.EqualFold(value, name) {
+			return timeFormat(i), nil
+		}
+	}
+	return 0, fmt.Errorf("log: unknown time format %q: want default, year or rfc3339", value)
+}
+
+// formatTime writes the time t of the header at buf.tmp[i] and returns the
+// index after it.
+func (l *loggingT) formatTime(buf *buffer, i int, t time.Time) int {
+	if l.timeUTC {
+		t = t.UTC()
+	}
+	if l.timeFormat == rfc3339Time {
+		return i + copy(buf.tmp[i:], l.appendTimestamp(buf.tmp[i:i], t))
+	}
+	year, month, day := t.Date()
+	hour, minute, second := t.Clock()
+	if l.timeFormat == yearTime {
+		buf.nDigits(4, i, year)
+		i += 4
+	}
+	buf.twoDigits(i, int(month))
+	buf.twoDigits(i+2, day)
+	buf.tmp[i+4] = ' '
+	buf.twoDigits(i+5, hour)
+	buf.tmp[i+7] = ':'
+	buf.twoDigits(i+8, minute)
+	buf.tmp[i+10] = ':'
+	buf.twoDigits(i+11, second)
+	buf.tmp[i+13] = '.'
+	if l.timeNanos {
+		buf.nDigits(9, i+14, t.Nanosecond())
+		return i + 23
+	}
+	buf.nDigits(6, i+14, t.Nanosecond()/1000)
+	return i + 20
+}
+
+// lineFormat describes the header of log lines for the header of log files.
+func (l *loggingT) lineFormat() string {
+	fraction := "uuuuuu"
+	if l.timeNanos {
+		fraction = "nnnnnnnnn"
+	}
+	var time string
+	switch l.timeFormat {
+	case rfc3339Time:
+		time = "yyyy-mm-ddThh:mm:ss." + fraction + "+hh:mm"
+	case yearTime:
+		time = "yyyymmdd hh:mm:ss." + fraction
+	default:
+		time = "mmdd hh:mm:ss." + fraction
+	}
+	format := "[TDIWEF]" + time + " threadid file:line] msg"
+	if l.timeUTC {
+		format += " (time in UTC)"
+	}
+	return format
+}
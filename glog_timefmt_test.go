@@ -0,0 +1,64 @@
+// Go support for leveled logs, analogous to https://code.google.com/p/google-glog/
+//
+// Modifications copyright 2013 Ernest Micklei. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeaderTimeFormats(t *testing.T) {
+	zone := time.FixedZone("CET", 3600)
+	r := &Record{Time: time.Date(2006, 1, 2, 15, 4, 5, 678901234, zone), ThreadID: 1234, File: "file.go", Line: 56, sev: warningLog}
+	for _, each := range []struct {
+		l    *loggingT
+		want string
+	}{
+		{&loggingT{}, "W0102 15:04:05.678901 01234 file.go:56] "},
+		{&loggingT{timeFormat: yearTime}, "W20060102 15:04:05.678901 01234 file.go:56] "},
+		{&loggingT{timeNanos: true}, "W0102 15:04:05.678901234 01234 file.go:56] "},
+		{&loggingT{timeUTC: true}, "W0102 14:04:05.678901 01234 file.go:56] "},
+		{&loggingT{timeFormat: rfc3339Time}, "W2006-01-02T15:04:05.678901+01:00 01234 file.go:56] "},
+		{&loggingT{timeFormat: rfc3339Time, timeUTC: true, timeNanos: true}, "W2006-01-02T14:04:05.678901234Z 01234 file.go:56] "},
+	} {
+		buf := each.l.formatHeader(r)
+		if got := buf.String(); got != each.want {
+			t.Errorf("got %q want %q", got, each.want)
+		}
+	}
+}
+
+func TestLineFormat(t *testing.T) {
+	for _, each := range []struct {
+		l    *loggingT
+		want string
+	}{
+		{&loggingT{}, "[TDIWEF]mmdd hh:mm:ss.uuuuuu threadid file:line] msg"},
+		{&loggingT{timeFormat: yearTime, timeNanos: true}, "[TDIWEF]yyyymmdd hh:mm:ss.nnnnnnnnn threadid file:line] msg"},
+		{&loggingT{timeFormat: rfc3339Time, timeUTC: true}, "[TDIWEF]yyyy-mm-ddThh:mm:ss.uuuuuu+hh:mm threadid file:line] msg (time in UTC)"},
+	} {
+		if got := each.l.lineFormat(); got != each.want {
+			t.Errorf("got %q want %q", got, each.want)
+		}
+	}
+	if _, err := parseTimeFormat("iso"); err == nil {
+		t.Error("expected error")
+	}
+	if f := timeFormat(7); f.String() != "timeFormat(7)" {
+		t.Errorf("got %q", f.String())
+	}
+}